@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/database"
+)
+
+const (
+	auditLogin            = "login"
+	auditTokenRefresh     = "token.refresh"
+	auditTokenRevoke      = "token.revoke"
+	auditPasswordChange   = "password.change"
+	auditPasswordReset    = "password.reset"
+	auditEmailChange      = "email.change"
+	auditChirpyRedUpgrade = "chirpy_red.upgrade"
+
+	auditSubscriptionDowngrade     = "subscription.downgrade"
+	auditSubscriptionPaymentFailed = "subscription.payment_failed"
+	auditSubscriptionCancel        = "subscription.cancel"
+	auditSubscriptionExpire        = "subscription.expire"
+
+	auditAdminUserDelete         = "admin.user.delete"
+	auditAdminUserSuspend        = "admin.user.suspend"
+	auditAdminUserUnsuspend      = "admin.user.unsuspend"
+	auditAdminUserPasswordReset  = "admin.user.password_reset"
+	auditAdminUserChirpyRed      = "admin.user.chirpy_red"
+	auditAdminUserCreate         = "admin.user.create"
+	auditAdminUserPromote        = "admin.user.promote"
+	auditAdminUserRevokeSessions = "admin.user.revoke_sessions"
+	auditAdminReset              = "admin.reset"
+	auditAdminWebhookReplay      = "admin.webhook.replay"
+)
+
+const (
+	auditSuccess = "success"
+	auditFailure = "failure"
+)
+
+// auditEvent is one entry for the audit log. A zero ActorID or TargetID is
+// stored as NULL, for example a failed login for an unknown email.
+type auditEvent struct {
+	Action   string
+	Outcome  string
+	ActorID  uuid.UUID
+	TargetID uuid.UUID
+	Detail   string
+}
+
+// audit records an event along with the caller's IP address and user agent.
+// Failures are only logged so a broken audit log never blocks a request.
+func (cfg *apiConfig) audit(r *http.Request, event auditEvent) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	cfg.recordAudit(r.Context(), event, ip, r.UserAgent())
+}
+
+// auditSystem records an event Chirpy took on its own, such as a background
+// job, with no client address.
+func (cfg *apiConfig) auditSystem(ctx context.Context, event auditEvent) {
+	cfg.recordAudit(ctx, event, "", "chirpy")
+}
+
+func (cfg *apiConfig) recordAudit(ctx context.Context, event auditEvent, ip, userAgent string) {
+	if event.Action == auditLogin {
+		cfg.metrics.Logins.WithLabelValues(event.Outcome).Inc()
+	}
+
+	err := cfg.database.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		Action:    event.Action,
+		Outcome:   event.Outcome,
+		ActorID:   uuid.NullUUID{UUID: event.ActorID, Valid: event.ActorID != uuid.Nil},
+		TargetID:  uuid.NullUUID{UUID: event.TargetID, Valid: event.TargetID != uuid.Nil},
+		IpAddress: ip,
+		UserAgent: userAgent,
+		Detail:    event.Detail,
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("Error recording audit event", "action", event.Action, "error", err)
+	}
+}
+
+// auditAdmin records an admin action taken by the user in the request
+// context against target. A non-nil err marks the action as failed.
+func (cfg *apiConfig) auditAdmin(r *http.Request, action string, target uuid.UUID, detail string, err error) {
+	event := auditEvent{
+		Action:   action,
+		Outcome:  auditSuccess,
+		TargetID: target,
+		Detail:   detail,
+	}
+	if admin, ok := userFromContext(r.Context()); ok {
+		event.ActorID = admin.ID
+	}
+	if err != nil {
+		event.Outcome = auditFailure
+		event.Detail = strings.TrimPrefix(detail+": "+err.Error(), ": ")
+	}
+
+	cfg.audit(r, event)
+}
+
+// auditCommand records an admin action taken from the command line, where
+// there's no signed-in actor. A non-nil err marks the action as failed.
+func (cfg *apiConfig) auditCommand(ctx context.Context, action string, target uuid.UUID, detail string, err error) {
+	event := auditEvent{
+		Action:   action,
+		Outcome:  auditSuccess,
+		TargetID: target,
+		Detail:   detail,
+	}
+	if err != nil {
+		event.Outcome = auditFailure
+		event.Detail = strings.TrimPrefix(detail+": "+err.Error(), ": ")
+	}
+
+	cfg.recordAudit(ctx, event, "", "chirpy-cli")
+}
+
+type AuditEvent struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Action    string     `json:"action"`
+	Outcome   string     `json:"outcome"`
+	ActorID   *uuid.UUID `json:"actor_id"`
+	TargetID  *uuid.UUID `json:"target_id"`
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	Detail    string     `json:"detail,omitempty"`
+}
+
+func (cfg *apiConfig) adminAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := database.ListAuditEventsParams{
+		MaxResults: 100,
+	}
+
+	if action := query.Get("action"); action != "" {
+		params.Action = sql.NullString{String: action, Valid: true}
+	}
+
+	switch outcome := query.Get("outcome"); outcome {
+	case "":
+	case auditSuccess, auditFailure:
+		params.Outcome = sql.NullString{String: outcome, Valid: true}
+	default:
+		respondWithError(w, r, http.StatusBadRequest, "outcome must be success or failure", nil)
+		return
+	}
+
+	for key, dest := range map[string]*uuid.NullUUID{
+		"actor_id":  &params.ActorID,
+		"target_id": &params.TargetID,
+	} {
+		if v := query.Get(key); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				respondWithError(w, r, http.StatusBadRequest, "Invalid "+key, err)
+				return
+			}
+			*dest = uuid.NullUUID{UUID: id, Valid: true}
+		}
+	}
+
+	for key, dest := range map[string]*sql.NullTime{
+		"since": &params.Since,
+		"until": &params.Until,
+	} {
+		if v := query.Get(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondWithError(w, r, http.StatusBadRequest, key+" must be an RFC 3339 timestamp", err)
+				return
+			}
+			*dest = sql.NullTime{Time: t.UTC(), Valid: true}
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			respondWithError(w, r, http.StatusBadRequest, "limit must be between 1 and 1000", err)
+			return
+		}
+		params.MaxResults = int32(limit)
+	}
+
+	dbEvents, err := cfg.database.ListAuditEvents(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list audit events", err)
+		return
+	}
+
+	events := []AuditEvent{}
+	for _, dbEvent := range dbEvents {
+		event := AuditEvent{
+			ID:        dbEvent.ID,
+			CreatedAt: dbEvent.CreatedAt,
+			Action:    dbEvent.Action,
+			Outcome:   dbEvent.Outcome,
+			IPAddress: dbEvent.IpAddress,
+			UserAgent: dbEvent.UserAgent,
+			Detail:    dbEvent.Detail,
+		}
+		if dbEvent.ActorID.Valid {
+			event.ActorID = &dbEvent.ActorID.UUID
+		}
+		if dbEvent.TargetID.Valid {
+			event.TargetID = &dbEvent.TargetID.UUID
+		}
+		events = append(events, event)
+	}
+
+	respondWithJSON(w, http.StatusOK, events)
+}
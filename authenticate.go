@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/tracing"
+)
+
+var (
+	errTokenRevoked      = errors.New("access token has been revoked")
+	errInsufficientScope = errors.New("access token is missing the required scope")
+	errAccountSuspended  = errors.New("account has been suspended")
+)
+
+// authenticate returns the user a request's bearer token was issued to.
+// First-party tokens may do anything; tokens issued to OAuth clients must
+// have been granted scope and must not have been revoked. Tokens issued to
+// users who have since been suspended are rejected.
+func (cfg *apiConfig) authenticate(r *http.Request, scope string) (_ uuid.UUID, err error) {
+	ctx, span := tracing.Start(r.Context(), "auth.authenticate")
+	defer func() { tracing.End(span, err) }()
+	r = r.WithContext(ctx)
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	claims, err := auth.ValidateAccessToken(token, cfg.secret)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	if !claims.IsThirdParty() {
+		err = cfg.checkNotSuspended(r.Context(), claims.UserID)
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+		noteRequestUser(r.Context(), claims.UserID)
+		return claims.UserID, nil
+	}
+
+	stored, err := cfg.database.GetOAuthAccessToken(r.Context(), claims.TokenID)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if stored.RevokedAt.Valid {
+		return uuid.UUID{}, errTokenRevoked
+	}
+
+	if !claims.HasScope(scope) {
+		return uuid.UUID{}, errInsufficientScope
+	}
+
+	err = cfg.checkNotSuspended(r.Context(), claims.UserID)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	noteRequestUser(r.Context(), claims.UserID)
+	return claims.UserID, nil
+}
+
+// authenticateFirstParty is authenticate for endpoints only Chirpy's own
+// clients may call, such as account settings, which never accept tokens
+// issued to OAuth clients.
+func (cfg *apiConfig) authenticateFirstParty(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	err = cfg.checkNotSuspended(r.Context(), userID)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	noteRequestUser(r.Context(), userID)
+	return userID, nil
+}
+
+// checkNotSuspended rejects tokens belonging to suspended users, which stay
+// otherwise valid until they expire.
+func (cfg *apiConfig) checkNotSuspended(ctx context.Context, userID uuid.UUID) error {
+	user, err := cfg.database.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.SuspendedAt.Valid {
+		return errAccountSuspended
+	}
+	return nil
+}
+
+// respondWithAuthError maps an error from authenticate to a response.
+func respondWithAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errAccountSuspended) {
+		respondWithError(w, r, http.StatusForbidden, "This account has been suspended", err)
+		return
+	}
+	if errors.Is(err, errInsufficientScope) {
+		respondWithError(w, r, http.StatusForbidden, "Token doesn't grant access to this action", err)
+		return
+	}
+	respondWithError(w, r, http.StatusUnauthorized, "Unable to verify token", err)
+}
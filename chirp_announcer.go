@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// chirpAnnounceBatchSize is how many newly published chirps are claimed at
+// a time.
+const chirpAnnounceBatchSize = 100
+
+// announcePublishedChirps emits chirp.created for scheduled chirps whose
+// publish time has come. Each chirp is claimed before its event is queued,
+// so it's announced at most once even with several instances running.
+func (cfg *apiConfig) announcePublishedChirps(ctx context.Context) error {
+	for {
+		chirps, err := cfg.database.ClaimUnannouncedChirps(ctx, chirpAnnounceBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, chirp := range chirps {
+			cfg.emitEvent(ctx, eventChirpCreated, chirp.UserID, newChirp(chirp))
+		}
+
+		if len(chirps) < chirpAnnounceBatchSize {
+			return nil
+		}
+	}
+}
+
+// runChirpAnnouncer announces newly published chirps every interval until
+// ctx is done.
+func (cfg *apiConfig) runChirpAnnouncer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	cfg.workers.started(workerChirpAnnouncer, interval)
+
+	for {
+		err := cfg.announcePublishedChirps(ctx)
+		cfg.workers.ran(workerChirpAnnouncer, err)
+		if err != nil {
+			cfg.logger.Error("Error announcing published chirps", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
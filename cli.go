@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/entitlements"
+)
+
+const cliUsage = `usage: chirpy [flags] <command> [arguments]
+
+Commands:
+  config                   print the effective configuration
+  migrate up|down|status   manage the database schema
+  create-user <email>      create a verified user, reading the password from stdin
+  promote-admin <email>    give a user the admin role
+  grant-red <email>        give a user Chirpy Red until it's revoked
+  revoke-sessions <email>  revoke a user's refresh tokens and OAuth access tokens
+  purge-refresh-tokens     delete expired refresh tokens
+  stats                    print counts of users, chirps and sessions
+`
+
+// runCommand handles the command-line mode of the chirpy binary, used for
+// operations that can't go through the API, like creating the first admin.
+// Changes to users are audited like their admin API counterparts.
+func (cfg *apiConfig) runCommand(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	switch args[0] {
+	case "create-user":
+		if len(args) != 2 {
+			return errors.New("usage: chirpy create-user <email> < password-file")
+		}
+		return cfg.createUserCommand(ctx, args[1], stdin, stdout)
+	case "promote-admin":
+		if len(args) != 2 {
+			return errors.New("usage: chirpy promote-admin <email>")
+		}
+
+		user, err := cfg.database.SetUserRoleByEmail(ctx, database.SetUserRoleByEmailParams{
+			Email: args[1],
+			Role:  roleAdmin,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no user with email %s", args[1])
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't promote %s: %w", args[1], err)
+		}
+		cfg.auditCommand(ctx, auditAdminUserPromote, user.ID, "", nil)
+
+		fmt.Fprintf(stdout, "%s (%s) is now an admin\n", user.Email, user.ID)
+		return nil
+	case "grant-red":
+		if len(args) != 2 {
+			return errors.New("usage: chirpy grant-red <email>")
+		}
+
+		user, err := cfg.userByEmail(ctx, args[1])
+		if err != nil {
+			return err
+		}
+
+		// Like grants through the admin API, this is an open-ended
+		// subscription that only ends when it's revoked.
+		err = cfg.activateSubscription(ctx, user.ID, entitlements.PlanChirpyRed, time.Time{})
+		cfg.auditCommand(ctx, auditAdminUserChirpyRed, user.ID, "granted", err)
+		if err != nil {
+			return fmt.Errorf("couldn't grant Chirpy Red to %s: %w", user.Email, err)
+		}
+
+		fmt.Fprintf(stdout, "%s (%s) now has Chirpy Red\n", user.Email, user.ID)
+		return nil
+	case "revoke-sessions":
+		if len(args) != 2 {
+			return errors.New("usage: chirpy revoke-sessions <email>")
+		}
+		return cfg.revokeSessionsCommand(ctx, args[1], stdout)
+	case "purge-refresh-tokens":
+		if len(args) != 1 {
+			return errors.New("usage: chirpy purge-refresh-tokens")
+		}
+
+		purged, err := cfg.database.DeleteExpiredRefreshTokens(ctx)
+		if err != nil {
+			return fmt.Errorf("couldn't purge refresh tokens: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "Purged %d expired refresh tokens\n", purged)
+		return nil
+	case "stats":
+		if len(args) != 1 {
+			return errors.New("usage: chirpy stats")
+		}
+		return cfg.statsCommand(ctx, stdout)
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], cliUsage)
+	}
+}
+
+// userByEmail looks up the user a command refers to.
+func (cfg *apiConfig) userByEmail(ctx context.Context, email string) (database.User, error) {
+	user, err := cfg.database.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("no user with email %s", email)
+	}
+	if err != nil {
+		return database.User{}, fmt.Errorf("couldn't get user %s: %w", email, err)
+	}
+	return user, nil
+}
+
+// createUserCommand creates a user with the password on the first line of
+// stdin, so it stays out of shell history and the process list. The operator
+// vouches for the address, so the user starts out verified.
+func (cfg *apiConfig) createUserCommand(ctx context.Context, email string, stdin io.Reader, stdout io.Writer) error {
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("couldn't read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return errors.New("a password is required on stdin")
+	}
+
+	err = cfg.passwordPolicy.Validate(password, email)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("couldn't hash password: %w", err)
+	}
+
+	user, err := cfg.database.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		cfg.auditCommand(ctx, auditAdminUserCreate, uuid.Nil, email, err)
+		return fmt.Errorf("couldn't create %s: %w", email, err)
+	}
+
+	_, err = cfg.database.MarkEmailVerified(ctx, database.MarkEmailVerifiedParams{
+		ID:    user.ID,
+		Email: user.Email,
+	})
+	cfg.auditCommand(ctx, auditAdminUserCreate, user.ID, "", err)
+	if err != nil {
+		return fmt.Errorf("created %s (%s) but couldn't verify their email: %w", user.Email, user.ID, err)
+	}
+
+	fmt.Fprintf(stdout, "Created %s (%s)\n", user.Email, user.ID)
+	return nil
+}
+
+// revokeSessionsCommand signs a user out everywhere. Access tokens are
+// stateless JWTs, so those already issued stay valid until they expire.
+func (cfg *apiConfig) revokeSessionsCommand(ctx context.Context, email string, stdout io.Writer) error {
+	user, err := cfg.userByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.database.RevokeAllRefreshTokensForUser(ctx, user.ID)
+	if err != nil {
+		cfg.auditCommand(ctx, auditAdminUserRevokeSessions, user.ID, "revoking refresh tokens", err)
+		return fmt.Errorf("couldn't revoke refresh tokens for %s: %w", user.Email, err)
+	}
+
+	revoked, err := cfg.database.RevokeAllOAuthAccessTokensForUser(ctx, user.ID)
+	if err != nil {
+		cfg.auditCommand(ctx, auditAdminUserRevokeSessions, user.ID, "revoking OAuth access tokens", err)
+		return fmt.Errorf("couldn't revoke OAuth access tokens for %s: %w", user.Email, err)
+	}
+
+	cfg.auditCommand(ctx, auditAdminUserRevokeSessions, user.ID, "", nil)
+	fmt.Fprintf(stdout, "Revoked all sessions and %d OAuth access tokens for %s (%s)\n", revoked, user.Email, user.ID)
+	return nil
+}
+
+func (cfg *apiConfig) statsCommand(ctx context.Context, stdout io.Writer) error {
+	stats, err := cfg.database.GetStats(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't get stats: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Users\t%d\n", stats.Users)
+	fmt.Fprintf(tw, "Admins\t%d\n", stats.Admins)
+	fmt.Fprintf(tw, "Chirpy Red users\t%d\n", stats.ChirpyRedUsers)
+	fmt.Fprintf(tw, "Suspended users\t%d\n", stats.SuspendedUsers)
+	fmt.Fprintf(tw, "Chirps\t%d\n", stats.Chirps)
+	fmt.Fprintf(tw, "Active sessions\t%d\n", stats.ActiveSessions)
+	fmt.Fprintf(tw, "Expired refresh tokens\t%d\n", stats.ExpiredRefreshTokens)
+	fmt.Fprintf(tw, "Pending webhook deliveries\t%d\n", stats.PendingWebhookDeliveries)
+	return tw.Flush()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/entitlements"
+)
+
+// planFor returns the plan a user's capabilities come from. Users without a
+// live subscription are on the catalog's default plan.
+func (cfg *apiConfig) planFor(ctx context.Context, userID uuid.UUID) (string, error) {
+	sub, err := cfg.database.GetSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cfg.entitlements.DefaultPlan, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if sub.Status == subscriptionExpired {
+		return cfg.entitlements.DefaultPlan, nil
+	}
+
+	return sub.Plan, nil
+}
+
+func (cfg *apiConfig) capabilitiesFor(ctx context.Context, userID uuid.UUID) (entitlements.Capabilities, error) {
+	plan, err := cfg.planFor(ctx, userID)
+	if err != nil {
+		return entitlements.Capabilities{}, err
+	}
+
+	return cfg.entitlements.For(plan), nil
+}
+
+func (cfg *apiConfig) entitlementsHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Plan         string                    `json:"plan"`
+		Capabilities entitlements.Capabilities `json:"capabilities"`
+	}
+
+	userID, err := cfg.authenticateFirstParty(r)
+	if err != nil {
+		respondWithAuthError(w, r, err)
+		return
+	}
+
+	plan, err := cfg.planFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't look up plan", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Plan:         plan,
+		Capabilities: cfg.entitlements.For(plan),
+	})
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/entitlements"
+)
+
+type AdminUser struct {
+	User
+	TotpEnabled    bool          `json:"totp_enabled"`
+	SuspendedAt    *time.Time    `json:"suspended_at"`
+	ActiveSessions int64         `json:"active_sessions,omitempty"`
+	Subscription   *Subscription `json:"subscription,omitempty"`
+}
+
+func newAdminUser(user database.User) AdminUser {
+	adminUser := AdminUser{
+		User: User{
+			ID:            user.ID,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+			Email:         user.Email,
+			IsChirpyRed:   user.IsChirpyRed,
+			EmailVerified: user.EmailVerified,
+			Role:          user.Role,
+		},
+		TotpEnabled: user.TotpEnabled,
+	}
+	if user.SuspendedAt.Valid {
+		adminUser.SuspendedAt = &user.SuspendedAt.Time
+	}
+	return adminUser
+}
+
+// likeEscaper escapes the ILIKE wildcards so a search is a plain substring
+// match.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (cfg *apiConfig) adminUsersSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 500 {
+			respondWithError(w, r, http.StatusBadRequest, "limit must be between 1 and 500", err)
+			return
+		}
+		limit = parsed
+	}
+
+	// Users have no handle yet, so a query that parses as a user ID is
+	// looked up directly and anything else is matched against the email.
+	if userID, err := uuid.Parse(query); err == nil {
+		user, err := cfg.database.GetUserByID(r.Context(), userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithJSON(w, http.StatusOK, []AdminUser{})
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't search users", err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, []AdminUser{newAdminUser(user)})
+		return
+	}
+
+	dbUsers, err := cfg.database.SearchUsersByEmail(r.Context(), database.SearchUsersByEmailParams{
+		Query:      likeEscaper.Replace(query),
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't search users", err)
+		return
+	}
+
+	users := []AdminUser{}
+	for _, dbUser := range dbUsers {
+		users = append(users, newAdminUser(dbUser))
+	}
+
+	respondWithJSON(w, http.StatusOK, users)
+}
+
+// adminUserFromPath loads the user named by the {userID} path value, writing
+// an error response and returning false if it can't.
+func (cfg *apiConfig) adminUserFromPath(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID", err)
+		return database.User{}, false
+	}
+
+	user, err := cfg.database.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
+		return database.User{}, false
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
+		return database.User{}, false
+	}
+
+	return user, true
+}
+
+func (cfg *apiConfig) adminUserGetHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := cfg.database.CountActiveSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't count sessions", err)
+		return
+	}
+
+	adminUser := newAdminUser(user)
+	adminUser.ActiveSessions = sessions
+
+	sub, err := cfg.database.GetSubscription(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get subscription", err)
+		return
+	}
+	if err == nil {
+		subscription := newSubscription(sub)
+		adminUser.Subscription = &subscription
+	}
+
+	respondWithJSON(w, http.StatusOK, adminUser)
+}
+
+func (cfg *apiConfig) adminUserSuspendHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if admin, ok := userFromContext(r.Context()); ok && admin.ID == user.ID {
+		respondWithError(w, r, http.StatusBadRequest, "You can't suspend yourself", nil)
+		return
+	}
+
+	updated, err := cfg.database.SuspendUser(r.Context(), user.ID)
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserSuspend, user.ID, "", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't suspend user", err)
+		return
+	}
+
+	err = cfg.database.RevokeAllRefreshTokensForUser(r.Context(), user.ID)
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserSuspend, user.ID, "revoking sessions", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	_, err = cfg.database.RevokeAllOAuthAccessTokensForUser(r.Context(), user.ID)
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserSuspend, user.ID, "revoking OAuth access tokens", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	cfg.auditAdmin(r, auditAdminUserSuspend, user.ID, "", nil)
+	respondWithJSON(w, http.StatusOK, newAdminUser(updated))
+}
+
+func (cfg *apiConfig) adminUserUnsuspendHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := cfg.database.UnsuspendUser(r.Context(), user.ID)
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserUnsuspend, user.ID, "", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't unsuspend user", err)
+		return
+	}
+
+	cfg.auditAdmin(r, auditAdminUserUnsuspend, user.ID, "", nil)
+	respondWithJSON(w, http.StatusOK, newAdminUser(updated))
+}
+
+// adminUserPasswordResetHandler locks the user out of their current password
+// and sessions, then emails them a reset token.
+func (cfg *apiConfig) adminUserPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.database.SetUserPassword(r.Context(), database.SetUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: "unset",
+	})
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserPasswordReset, user.ID, "clearing password", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't clear password", err)
+		return
+	}
+
+	err = cfg.database.RevokeAllRefreshTokensForUser(r.Context(), user.ID)
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserPasswordReset, user.ID, "revoking sessions", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	_, err = cfg.database.RevokeAllOAuthAccessTokensForUser(r.Context(), user.ID)
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserPasswordReset, user.ID, "revoking OAuth access tokens", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	err = cfg.sendPasswordResetEmail(r.Context(), user)
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserPasswordReset, user.ID, "sending reset email", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't send password reset email", err)
+		return
+	}
+
+	cfg.auditAdmin(r, auditAdminUserPasswordReset, user.ID, "", nil)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) adminUserChirpyRedHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		IsChirpyRed *bool `json:"is_chirpy_red"`
+	}
+
+	user, ok := cfg.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		return
+	}
+
+	if params.IsChirpyRed == nil {
+		respondWithError(w, r, http.StatusBadRequest, "is_chirpy_red is required", nil)
+		return
+	}
+
+	detail := "revoked"
+	if *params.IsChirpyRed {
+		detail = "granted"
+	}
+
+	// Grants are open-ended subscriptions; revoking expires whatever
+	// subscription the user had.
+	if *params.IsChirpyRed {
+		err = cfg.activateSubscription(r.Context(), user.ID, entitlements.PlanChirpyRed, time.Time{})
+	} else {
+		err = cfg.changeSubscriptionStatus(r.Context(), user.ID, subscriptionExpired)
+		if errors.Is(err, errNoSubscription) {
+			_, err = cfg.database.SetChirpyRed(r.Context(), database.SetChirpyRedParams{
+				ID:          user.ID,
+				IsChirpyRed: false,
+			})
+		}
+	}
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserChirpyRed, user.ID, detail, err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update Chirpy Red", err)
+		return
+	}
+
+	cfg.auditAdmin(r, auditAdminUserChirpyRed, user.ID, detail, nil)
+
+	updated, err := cfg.database.GetUserByID(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAdminUser(updated))
+}
+
+func (cfg *apiConfig) adminUserDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	if admin, ok := userFromContext(r.Context()); ok && admin.ID == userID {
+		respondWithError(w, r, http.StatusBadRequest, "You can't delete yourself", nil)
+		return
+	}
+
+	deleted, err := cfg.database.DeleteUser(r.Context(), userID)
+	if err != nil {
+		cfg.auditAdmin(r, auditAdminUserDelete, userID, "", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", nil)
+		return
+	}
+
+	cfg.auditAdmin(r, auditAdminUserDelete, userID, "", nil)
+	w.WriteHeader(http.StatusNoContent)
+}
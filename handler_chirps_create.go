@@ -1,14 +1,15 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
-	"github.com/jzetterman/chirpy/internal/auth"
 	"github.com/jzetterman/chirpy/internal/database"
 )
 
@@ -16,62 +17,117 @@ type Chirp struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	PublishAt time.Time `json:"publish_at"`
 	Body      string    `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
+	MediaURL  string    `json:"media_url,omitempty"`
+}
+
+func newChirp(chirp database.Chirp) Chirp {
+	return Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		PublishAt: chirp.PublishAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		MediaURL:  chirp.MediaPath.String,
+	}
 }
 
 func (cfg *apiConfig) chirpsPostHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body      string     `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Error retrieving the token", err)
+		respondWithAuthError(w, r, err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Error validating the JWT token", err)
-		return
+	if cfg.requireEmailVerification {
+		user, err := cfg.database.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, r, http.StatusUnauthorized, "Couldn't find user", err)
+			return
+		}
+		if !user.EmailVerified {
+			respondWithError(w, r, http.StatusForbidden, "Verify your email address before posting chirps", nil)
+			return
+		}
 	}
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
 	err = decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error decoding parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Error decoding parameters", err)
+		return
+	}
+
+	capabilities, err := cfg.capabilitiesFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't look up plan", err)
 		return
 	}
 
-	cleaned, err := validateChirp(params.Body)
+	if capabilities.ChirpsPerHour > 0 {
+		recent, err := cfg.database.CountChirpsSince(r.Context(), database.CountChirpsSinceParams{
+			UserID:    userID,
+			CreatedAt: time.Now().UTC().Add(-time.Hour),
+		})
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't check chirp rate limit", err)
+			return
+		}
+		if recent >= int64(capabilities.ChirpsPerHour) {
+			respondWithError(w, r, http.StatusTooManyRequests, "Your plan's hourly chirp limit has been reached", nil)
+			return
+		}
+	}
+
+	publishAt := time.Now().UTC()
+	scheduled := params.PublishAt != nil && params.PublishAt.After(publishAt)
+	if scheduled {
+		if !capabilities.ScheduleChirps {
+			respondWithError(w, r, http.StatusForbidden, "Your plan doesn't include scheduled chirps", nil)
+			return
+		}
+		publishAt = params.PublishAt.UTC()
+	}
+
+	cleaned, err := validateChirp(params.Body, capabilities.MaxChirpLength)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), err)
+		return
 	}
 
 	chirp, err := cfg.database.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleaned,
-		UserID: userID,
+		Body:      cleaned,
+		UserID:    userID,
+		PublishAt: publishAt,
+		// Scheduled chirps are announced by runChirpAnnouncer once they're
+		// published.
+		AnnouncedAt: sql.NullTime{Time: publishAt, Valid: !scheduled},
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Error creating chirp", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-	})
+	cfg.metrics.ChirpsCreated.Inc()
+	if !scheduled {
+		cfg.emitEvent(r.Context(), eventChirpCreated, userID, newChirp(chirp))
+	}
+
+	respondWithJSON(w, http.StatusCreated, newChirp(chirp))
 }
 
-func validateChirp(body string) (string, error) {
-	const maxChirpLenth = 140
-	if len(body) > maxChirpLenth {
-		return "", errors.New("Chirp is too long")
+func validateChirp(body string, maxLength int) (string, error) {
+	if utf8.RuneCountInString(body) > maxLength {
+		return "", fmt.Errorf("Chirp is too long, your plan allows %d characters", maxLength)
 	}
 
 	badWords := map[string]struct{}{
@@ -5,49 +5,51 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
-	"github.com/jzetterman/chirpy/internal/auth"
 )
 
 func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	authedUserID, err := cfg.authenticate(r, scopeChirpsDelete)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find token", err)
-		return
-	}
-
-	authedUserID, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unable to verify token", err)
+		respondWithAuthError(w, r, err)
 		return
 	}
 
 	chirp, err := cfg.database.GetOneChirp(r.Context(), chirpID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+			respondWithError(w, r, http.StatusNotFound, "Couldn't get chirp", err)
 			return
 		}
-		respondWithError(w, http.StatusInternalServerError, "Server didn't handle request", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Server didn't handle request", err)
 		return
 	}
 
 	if chirp.UserID != authedUserID {
-		respondWithError(w, http.StatusForbidden, "You can't delete this chirp", err)
+		respondWithError(w, r, http.StatusForbidden, "You can't delete this chirp", err)
 		return
 	}
 
 	err = cfg.database.DeleteChirp(r.Context(), chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete chirp", err)
 		return
 	}
 
+	cfg.removeMedia(chirp.MediaPath)
+	cfg.emitEvent(r.Context(), eventChirpDeleted, chirp.UserID, struct {
+		ID     uuid.UUID `json:"id"`
+		UserID uuid.UUID `json:"user_id"`
+	}{
+		ID:     chirp.ID,
+		UserID: chirp.UserID,
+	})
+
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -11,23 +11,17 @@ func (cfg *apiConfig) chirpGetHandler(w http.ResponseWriter, r *http.Request) {
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
 		return
 	}
 
-	dbChirp, err := cfg.database.GetOneChirp(r.Context(), chirpID)
+	dbChirp, err := cfg.database.GetPublishedChirp(r.Context(), chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		respondWithError(w, r, http.StatusNotFound, "Couldn't get chirp", err)
 		return
 	}
 
-	respondWithJSON(w, 200, Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		UserID:    dbChirp.UserID,
-		Body:      dbChirp.Body,
-	})
+	respondWithJSON(w, 200, newChirp(dbChirp))
 }
 
 func (cfg *apiConfig) chirpsGetHandler(w http.ResponseWriter, r *http.Request) {
@@ -39,32 +33,26 @@ func (cfg *apiConfig) chirpsGetHandler(w http.ResponseWriter, r *http.Request) {
 	if authorID == "" {
 		dbChirps, err = cfg.database.GetChirps(r.Context())
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
 			return
 		}
 	} else {
 		authorUUID, err := uuid.Parse(authorID)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "Unable to parse UUID", err)
+			respondWithError(w, r, http.StatusBadRequest, "Unable to parse UUID", err)
 			return
 		}
 
 		dbChirps, err = cfg.database.GetChirpsByAuthorID(r.Context(), authorUUID)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
 			return
 		}
 	}
 
 	chirps := []Chirp{}
 	for _, dbChirp := range dbChirps {
-		chirps = append(chirps, Chirp{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			UserID:    dbChirp.UserID,
-			Body:      dbChirp.Body,
-		})
+		chirps = append(chirps, newChirp(dbChirp))
 	}
 
 	if sort == "desc" {
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/database"
+)
+
+const mediaURLPrefix = "/media/"
+
+// mediaExtensions lists the content types accepted as chirp media, sniffed
+// from the upload rather than trusted from the client.
+var mediaExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+func (cfg *apiConfig) chirpMediaUploadHandler(w http.ResponseWriter, r *http.Request) {
+	chirp, capabilities, ok := cfg.ownChirpFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if !capabilities.MediaUploads {
+		respondWithError(w, r, http.StatusForbidden, "Your plan doesn't include media uploads", nil)
+		return
+	}
+
+	// Leave room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, capabilities.MaxMediaBytes+1<<20)
+	file, header, err := r.FormFile("media")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, r, http.StatusRequestEntityTooLarge, "Media is larger than your plan allows", err)
+			return
+		}
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't read media file", err)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > capabilities.MaxMediaBytes {
+		respondWithError(w, r, http.StatusRequestEntityTooLarge, "Media is larger than your plan allows", nil)
+		return
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't read media file", err)
+		return
+	}
+
+	contentType := http.DetectContentType(sniff[:n])
+	extension, ok := mediaExtensions[contentType]
+	if !ok {
+		respondWithError(w, r, http.StatusUnsupportedMediaType, "Media must be a PNG, JPEG, GIF or WebP image", nil)
+		return
+	}
+
+	err = os.MkdirAll(cfg.mediaDir, 0o755)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't store media", err)
+		return
+	}
+
+	name := uuid.NewString() + extension
+	dst, err := os.Create(filepath.Join(cfg.mediaDir, name))
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't store media", err)
+		return
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, io.MultiReader(bytes.NewReader(sniff[:n]), file))
+	if err != nil {
+		os.Remove(dst.Name())
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't store media", err)
+		return
+	}
+
+	updated, err := cfg.database.SetChirpMedia(r.Context(), database.SetChirpMediaParams{
+		ID:        chirp.ID,
+		MediaPath: sql.NullString{String: mediaURLPrefix + name, Valid: true},
+	})
+	if err != nil {
+		os.Remove(dst.Name())
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't attach media", err)
+		return
+	}
+
+	cfg.removeMedia(chirp.MediaPath)
+
+	respondWithJSON(w, http.StatusOK, newChirp(updated))
+}
+
+// mediaHandler serves uploaded chirp media without directory listings.
+func (cfg *apiConfig) mediaHandler() http.Handler {
+	files := http.StripPrefix(mediaURLPrefix, http.FileServer(http.Dir(cfg.mediaDir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// removeMedia deletes a chirp's media file once nothing refers to it.
+// Failures are only logged; a stray file does no harm.
+func (cfg *apiConfig) removeMedia(path sql.NullString) {
+	if !path.Valid {
+		return
+	}
+
+	name := filepath.Base(strings.TrimPrefix(path.String, mediaURLPrefix))
+	err := os.Remove(filepath.Join(cfg.mediaDir, name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		cfg.logger.Error("Error removing media", "file", name, "error", err)
+	}
+}
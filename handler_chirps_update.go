@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/entitlements"
+)
+
+// ownChirpFromPath loads the chirp named by the {chirpID} path value along
+// with its author's capabilities, writing an error response and returning
+// false unless the caller wrote it.
+func (cfg *apiConfig) ownChirpFromPath(w http.ResponseWriter, r *http.Request) (database.Chirp, entitlements.Capabilities, bool) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
+		return database.Chirp{}, entitlements.Capabilities{}, false
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, r, err)
+		return database.Chirp{}, entitlements.Capabilities{}, false
+	}
+
+	chirp, err := cfg.database.GetOneChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't get chirp", err)
+		return database.Chirp{}, entitlements.Capabilities{}, false
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return database.Chirp{}, entitlements.Capabilities{}, false
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, r, http.StatusForbidden, "You can't change this chirp", nil)
+		return database.Chirp{}, entitlements.Capabilities{}, false
+	}
+
+	capabilities, err := cfg.capabilitiesFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't look up plan", err)
+		return database.Chirp{}, entitlements.Capabilities{}, false
+	}
+
+	return chirp, capabilities, true
+}
+
+func (cfg *apiConfig) chirpUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	chirp, capabilities, ok := cfg.ownChirpFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if !capabilities.EditChirps {
+		respondWithError(w, r, http.StatusForbidden, "Your plan doesn't include editing chirps", nil)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Error decoding parameters", err)
+		return
+	}
+
+	cleaned, err := validateChirp(params.Body, capabilities.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	updated, err := cfg.database.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: cleaned,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newChirp(updated))
+}
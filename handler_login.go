@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -15,42 +16,132 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 
-	type response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-	}
-
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode provided parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode provided parameters", err)
 		return
 	}
 
 	user, err := cfg.database.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		// The submitted email isn't recorded: the audit log can't be
+		// edited, and this is often a password typed in the wrong field.
+		cfg.audit(r, auditEvent{
+			Action:  auditLogin,
+			Outcome: auditFailure,
+			Detail:  "unknown email",
+		})
+		respondWithError(w, r, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
 
 	err = auth.CheckPasswordHash(user.HashedPassword, params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		cfg.audit(r, auditEvent{
+			Action:   auditLogin,
+			Outcome:  auditFailure,
+			ActorID:  user.ID,
+			TargetID: user.ID,
+			Detail:   "incorrect password",
+		})
+		respondWithError(w, r, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
 
+	if auth.PasswordNeedsRehash(user.HashedPassword) {
+		cfg.rehashPassword(r.Context(), user, params.Password)
+	}
+
+	cfg.completeLogin(w, r, user, "password")
+}
+
+// completeLogin finishes a first-factor login made with method. Suspended
+// users are turned away before anything else, users with two-factor
+// authentication get a challenge token to exchange at /api/login/totp, and
+// everyone else gets their tokens straight away.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User, method string) {
+	if cfg.rejectSuspendedLogin(w, r, user, method) {
+		return
+	}
+
+	if user.TotpEnabled {
+		challengeID, err := cfg.newTOTPChallenge(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, r, 500, "Error creating TOTP challenge", err)
+			return
+		}
+
+		challenge, err := auth.MakeTOTPChallenge(user.ID, challengeID, cfg.secret, totpChallengeTTL)
+		if err != nil {
+			respondWithError(w, r, 500, "Error creating TOTP challenge", err)
+			return
+		}
+
+		respondWithJSON(w, http.StatusAccepted, totpChallengeResponse{
+			TOTPRequired:   true,
+			ChallengeToken: challenge,
+		})
+		return
+	}
+
+	cfg.finishLogin(w, r, user, method)
+}
+
+// rejectSuspendedLogin turns away a login by a suspended user, recording it
+// in the audit log, and reports whether it did.
+func (cfg *apiConfig) rejectSuspendedLogin(w http.ResponseWriter, r *http.Request, user database.User, method string) bool {
+	if !user.SuspendedAt.Valid {
+		return false
+	}
+
+	cfg.audit(r, auditEvent{
+		Action:   auditLogin,
+		Outcome:  auditFailure,
+		ActorID:  user.ID,
+		TargetID: user.ID,
+		Detail:   method + ": account suspended",
+	})
+	respondWithError(w, r, http.StatusForbidden, "This account has been suspended", nil)
+	return true
+}
+
+// finishLogin issues tokens to a fully authenticated user, who has already
+// been checked for suspension, and records the login in the audit log.
+func (cfg *apiConfig) finishLogin(w http.ResponseWriter, r *http.Request, user database.User, method string) {
+	if cfg.respondWithTokens(w, r, user) {
+		noteRequestUser(r.Context(), user.ID)
+		cfg.audit(r, auditEvent{
+			Action:   auditLogin,
+			Outcome:  auditSuccess,
+			ActorID:  user.ID,
+			TargetID: user.ID,
+			Detail:   method,
+		})
+	}
+}
+
+// respondWithTokens issues a new access and refresh token pair for a user who
+// has finished authenticating and isn't suspended. It reports whether the
+// tokens were issued.
+func (cfg *apiConfig) respondWithTokens(w http.ResponseWriter, r *http.Request, user database.User) bool {
+	type response struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
 	jwt, err := auth.MakeJWT(user.ID, cfg.secret, time.Duration(3600)*time.Second)
 	if err != nil {
-		respondWithError(w, 500, "Error creating JWT token", err)
-		return
+		respondWithError(w, r, 500, "Error creating JWT token", err)
+		return false
 	}
 
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, 500, "Error creating refresh token", err)
-		return
+		respondWithError(w, r, 500, "Error creating refresh token", err)
+		return false
 	}
 
 	_, err = cfg.database.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
@@ -59,19 +150,40 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		ExpiresAt: time.Now().UTC().Add(time.Hour * 24 * 60),
 	})
 	if err != nil {
-		respondWithError(w, 500, "Error saving refresh token to database", err)
-		return
+		respondWithError(w, r, 500, "Error saving refresh token to database", err)
+		return false
 	}
 
 	respondWithJSON(w, 200, response{
 		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
+			ID:            user.ID,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+			Email:         user.Email,
+			IsChirpyRed:   user.IsChirpyRed,
+			EmailVerified: user.EmailVerified,
+			Role:          user.Role,
 		},
 		Token:        jwt,
 		RefreshToken: refreshToken,
 	})
+	return true
+}
+
+// rehashPassword upgrades a stored hash to the current hasher after a
+// successful login. Failures are only logged since the old hash still works.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, user database.User, password string) {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		loggerFromContext(ctx).Error("Error rehashing password", "user_id", user.ID, "error", err)
+		return
+	}
+
+	err = cfg.database.SetUserPassword(ctx, database.SetUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("Error saving rehashed password", "user_id", user.ID, "error", err)
+	}
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/oidc"
+)
+
+const (
+	oidcStateCookie = "chirpy_oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+func (cfg *apiConfig) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidc == nil {
+		respondWithError(w, r, http.StatusNotFound, "Single sign-on isn't configured", nil)
+		return
+	}
+
+	state, err := auth.MakeOneTimeToken()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create login state", err)
+		return
+	}
+
+	nonce, err := auth.MakeOneTimeToken()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create login nonce", err)
+		return
+	}
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create PKCE challenge", err)
+		return
+	}
+
+	err = cfg.database.CreateOIDCLoginState(r.Context(), database.CreateOIDCLoginStateParams{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().UTC().Add(oidcStateTTL),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save login state", err)
+		return
+	}
+
+	// The cookie ties the callback to the browser that started the login, so
+	// someone else's authorization code can't be planted on a victim.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/login/oidc",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.baseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, cfg.oidc.AuthCodeURL(state, nonce, challenge), http.StatusFound)
+}
+
+func (cfg *apiConfig) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidc == nil {
+		respondWithError(w, r, http.StatusNotFound, "Single sign-on isn't configured", nil)
+		return
+	}
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Identity provider returned "+providerErr, nil)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		respondWithError(w, r, http.StatusUnauthorized, "Login state doesn't match", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   oidcStateCookie,
+		Path:   "/api/login/oidc",
+		MaxAge: -1,
+	})
+
+	loginState, err := cfg.database.UseOIDCLoginState(r.Context(), query.Get("state"))
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Login state is invalid or expired", err)
+		return
+	}
+
+	rawIDToken, err := cfg.oidc.Exchange(r.Context(), query.Get("code"), loginState.CodeVerifier)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't exchange authorization code", err)
+		return
+	}
+
+	idToken, err := cfg.oidc.VerifyIDToken(r.Context(), rawIDToken, loginState.Nonce)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't verify ID token", err)
+		return
+	}
+
+	user, err := cfg.userForIdentity(r, idToken)
+	if err != nil {
+		cfg.audit(r, auditEvent{
+			Action:  auditLogin,
+			Outcome: auditFailure,
+			Detail:  "oidc " + idToken.Issuer + " " + idToken.Subject + ": " + err.Error(),
+		})
+		if errors.Is(err, errUnverifiedIdentityEmail) {
+			respondWithError(w, r, http.StatusForbidden, "Identity provider didn't supply a verified email address", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't sign in with identity provider", err)
+		return
+	}
+
+	cfg.completeLogin(w, r, user, "oidc")
+}
+
+var errUnverifiedIdentityEmail = errors.New("identity has no verified email")
+
+// userForIdentity finds the Chirpy user for an external identity, linking it
+// to an existing account with the same verified email or creating a new
+// account on first sign-in.
+func (cfg *apiConfig) userForIdentity(r *http.Request, idToken oidc.IDToken) (database.User, error) {
+	user, err := cfg.database.GetUserByIdentity(r.Context(), database.GetUserByIdentityParams{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+	})
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if idToken.Email == "" || !idToken.EmailVerified {
+		return database.User{}, errUnverifiedIdentityEmail
+	}
+
+	user, err = cfg.database.GetUserByEmail(r.Context(), idToken.Email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// SSO-only accounts get the same placeholder hash the users
+		// migration used, which never matches a password. They can set one
+		// later through a password reset.
+		user, err = cfg.database.CreateUser(r.Context(), database.CreateUserParams{
+			Email:          idToken.Email,
+			HashedPassword: "unset",
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	case err != nil:
+		return database.User{}, err
+	case !user.EmailVerified:
+		// Whoever registered this address never proved they own it, while
+		// the provider just did. Drop their password and sessions so an
+		// account set up in advance by someone else can't be taken over.
+		err = cfg.database.SetUserPassword(r.Context(), database.SetUserPasswordParams{
+			ID:             user.ID,
+			HashedPassword: "unset",
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+
+		err = cfg.database.RevokeAllRefreshTokensForUser(r.Context(), user.ID)
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+
+	err = cfg.database.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		UserID:  user.ID,
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return cfg.database.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{
+		ID:    user.ID,
+		Email: user.Email,
+	})
+}
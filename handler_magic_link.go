@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/mailer"
+)
+
+const magicLinkTTL = 15 * time.Minute
+
+func (cfg *apiConfig) magicLinkRequestHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		return
+	}
+
+	// Don't reveal whether an account exists for the address, including
+	// through how long the response takes.
+	cfg.runInBackground(r.Context(), func(ctx context.Context) {
+		user, err := cfg.database.GetUserByEmail(ctx, params.Email)
+		if err != nil {
+			return
+		}
+
+		err = cfg.sendMagicLink(ctx, user)
+		if err != nil {
+			loggerFromContext(ctx).Error("Error sending login link", "user_id", user.ID, "error", err)
+		}
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) sendMagicLink(ctx context.Context, user database.User) error {
+	token, linkID, err := auth.MakeMagicLinkToken(user.ID, cfg.secret, magicLinkTTL)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.database.CreateMagicLink(ctx, database.CreateMagicLinkParams{
+		ID:        linkID,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(magicLinkTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := cfg.baseURL + "/api/login/magic?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy login link",
+		Body: fmt.Sprintf(
+			"Open this link within 15 minutes to log in to Chirpy:\n\n%s\n\n"+
+				"The link can only be used once. If you didn't ask for it, you can ignore this email.\n",
+			link,
+		),
+	})
+}
+
+func (cfg *apiConfig) magicLinkLoginHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, r, http.StatusBadRequest, "Missing login token", nil)
+		return
+	}
+
+	userID, linkID, err := auth.ValidateMagicLinkToken(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired login link", err)
+		return
+	}
+
+	_, err = cfg.database.UseMagicLink(r.Context(), database.UseMagicLinkParams{
+		ID:     linkID,
+		UserID: userID,
+	})
+	if err != nil {
+		cfg.audit(r, auditEvent{
+			Action:   auditLogin,
+			Outcome:  auditFailure,
+			ActorID:  userID,
+			TargetID: userID,
+			Detail:   "invalid or used magic link",
+		})
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired login link", err)
+		return
+	}
+
+	user, err := cfg.database.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired login link", err)
+		return
+	}
+
+	cfg.completeLogin(w, r, user, "magic link")
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+)
+
+const (
+	scopeChirpsWrite  = "chirps:write"
+	scopeChirpsDelete = "chirps:delete"
+)
+
+// oauthScopes lists the scopes third-party clients can ask for, with the
+// description shown on the consent page.
+var oauthScopes = map[string]string{
+	scopeChirpsWrite:  "Post chirps as you",
+	scopeChirpsDelete: "Delete your chirps",
+}
+
+const oauthCodeTTL = 10 * time.Minute
+
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	Secret       string    `json:"client_secret,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+}
+
+func (cfg *apiConfig) oauthClientCreateHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	userID, err := cfg.authenticateFirstParty(r)
+	if err != nil {
+		respondWithAuthError(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		return
+	}
+
+	if strings.TrimSpace(params.Name) == "" {
+		respondWithError(w, r, http.StatusBadRequest, "Client name is required", nil)
+		return
+	}
+
+	if len(params.RedirectURIs) == 0 {
+		respondWithError(w, r, http.StatusBadRequest, "At least one redirect URI is required", nil)
+		return
+	}
+
+	for _, redirectURI := range params.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			respondWithError(w, r, http.StatusBadRequest, "Redirect URIs must be absolute https URLs, or http on localhost, without a fragment", nil)
+			return
+		}
+	}
+
+	secret := ""
+	secretHash := sql.NullString{}
+	if params.Confidential {
+		secret, err = auth.MakeOneTimeToken()
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't create client secret", err)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.database.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           uuid.NewString(),
+		Name:         params.Name,
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+		OwnerID:      userID,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create client", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, OAuthClient{
+		ID:           client.ID,
+		Secret:       secret,
+		CreatedAt:    client.CreatedAt,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+	})
+}
+
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
+
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scopes        []string
+	State         string
+	CodeChallenge string
+}
+
+// authorizeError is an error in an authorization request. Errors found
+// before the client and redirect URI are known to be good are shown to the
+// user; the rest are sent back to the client on the redirect URI.
+type authorizeError struct {
+	Code        string
+	Description string
+	Redirect    bool
+}
+
+func (cfg *apiConfig) parseAuthorizeRequest(r *http.Request, values url.Values) (authorizeRequest, *authorizeError) {
+	client, err := cfg.database.GetOAuthClient(r.Context(), values.Get("client_id"))
+	if err != nil {
+		return authorizeRequest{}, &authorizeError{Code: "invalid_client", Description: "Unknown client"}
+	}
+
+	redirectURI := values.Get("redirect_uri")
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return authorizeRequest{}, &authorizeError{Code: "invalid_request", Description: "Redirect URI isn't registered for this client"}
+	}
+
+	req := authorizeRequest{
+		Client:        client,
+		RedirectURI:   redirectURI,
+		State:         values.Get("state"),
+		CodeChallenge: values.Get("code_challenge"),
+		Scopes:        auth.ParseScope(values.Get("scope")),
+	}
+
+	if values.Get("response_type") != "code" {
+		return req, &authorizeError{Code: "unsupported_response_type", Description: "Only the code response type is supported", Redirect: true}
+	}
+
+	if req.CodeChallenge == "" || values.Get("code_challenge_method") != "S256" {
+		return req, &authorizeError{Code: "invalid_request", Description: "PKCE with the S256 method is required", Redirect: true}
+	}
+
+	if len(req.Scopes) == 0 {
+		return req, &authorizeError{Code: "invalid_scope", Description: "At least one scope is required", Redirect: true}
+	}
+
+	for _, scope := range req.Scopes {
+		if _, ok := oauthScopes[scope]; !ok {
+			return req, &authorizeError{Code: "invalid_scope", Description: "Unknown scope " + scope, Redirect: true}
+		}
+	}
+
+	return req, nil
+}
+
+func redirectWithAuthorizeResult(w http.ResponseWriter, r *http.Request, req authorizeRequest, params url.Values) {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	target, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Invalid redirect URI", err)
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func handleAuthorizeError(w http.ResponseWriter, r *http.Request, req authorizeRequest, authErr *authorizeError) {
+	if !authErr.Redirect {
+		renderConsentPage(w, r, http.StatusBadRequest, consentPage{Error: authErr.Description})
+		return
+	}
+
+	redirectWithAuthorizeResult(w, r, req, url.Values{
+		"error":             {authErr.Code},
+		"error_description": {authErr.Description},
+	})
+}
+
+func (cfg *apiConfig) oauthAuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	req, authErr := cfg.parseAuthorizeRequest(r, r.URL.Query())
+	if authErr != nil {
+		handleAuthorizeError(w, r, req, authErr)
+		return
+	}
+
+	renderConsentPage(w, r, http.StatusOK, newConsentPage(req))
+}
+
+func (cfg *apiConfig) oauthAuthorizeSubmitHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't parse form", err)
+		return
+	}
+
+	req, authErr := cfg.parseAuthorizeRequest(r, r.PostForm)
+	if authErr != nil {
+		handleAuthorizeError(w, r, req, authErr)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "allow" {
+		redirectWithAuthorizeResult(w, r, req, url.Values{
+			"error":             {"access_denied"},
+			"error_description": {"The user denied the request"},
+		})
+		return
+	}
+
+	page := newConsentPage(req)
+	page.Email = r.PostForm.Get("email")
+
+	user, err := cfg.database.GetUserByEmail(r.Context(), r.PostForm.Get("email"))
+	if err != nil {
+		page.Error = "Incorrect email or password"
+		renderConsentPage(w, r, http.StatusUnauthorized, page)
+		return
+	}
+
+	err = auth.CheckPasswordHash(user.HashedPassword, r.PostForm.Get("password"))
+	if err != nil {
+		cfg.audit(r, auditEvent{
+			Action:   auditLogin,
+			Outcome:  auditFailure,
+			ActorID:  user.ID,
+			TargetID: user.ID,
+			Detail:   "oauth consent for " + req.Client.ID + ": incorrect password",
+		})
+		page.Error = "Incorrect email or password"
+		renderConsentPage(w, r, http.StatusUnauthorized, page)
+		return
+	}
+
+	if user.SuspendedAt.Valid {
+		page.Error = "This account has been suspended"
+		renderConsentPage(w, r, http.StatusForbidden, page)
+		return
+	}
+
+	if user.TotpEnabled {
+		err = cfg.checkConsentTOTP(r.Context(), user, r.PostForm.Get("totp_code"))
+		switch {
+		case errors.Is(err, errTOTPLockedOut):
+			cfg.audit(r, auditEvent{
+				Action:   auditLogin,
+				Outcome:  auditFailure,
+				ActorID:  user.ID,
+				TargetID: user.ID,
+				Detail:   "oauth consent for " + req.Client.ID + ": " + err.Error(),
+			})
+			page.Error = "Too many failed attempts, try again later"
+			renderConsentPage(w, r, http.StatusTooManyRequests, page)
+			return
+		case errors.Is(err, errInvalidTOTPCode), errors.Is(err, errReusedTOTPCode):
+			cfg.audit(r, auditEvent{
+				Action:   auditLogin,
+				Outcome:  auditFailure,
+				ActorID:  user.ID,
+				TargetID: user.ID,
+				Detail:   "oauth consent for " + req.Client.ID + ": " + err.Error(),
+			})
+			page.Error = "Invalid two-factor code"
+			renderConsentPage(w, r, http.StatusUnauthorized, page)
+			return
+		case err != nil:
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't check two-factor code", err)
+			return
+		}
+	}
+
+	code, err := auth.MakeOneTimeToken()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create authorization code", err)
+		return
+	}
+
+	err = cfg.database.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.RedirectURI,
+		Scope:         strings.Join(req.Scopes, " "),
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().UTC().Add(oauthCodeTTL),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save authorization code", err)
+		return
+	}
+
+	redirectWithAuthorizeResult(w, r, req, url.Values{
+		"code": {code},
+	})
+}
+
+// checkConsentTOTP checks the two-factor code on the consent form under the
+// same lockout and replay protection as /api/login/totp. The form is a
+// single step, so each submission gets its own challenge to count a wrong
+// code against.
+func (cfg *apiConfig) checkConsentTOTP(ctx context.Context, user database.User, code string) error {
+	err := cfg.checkTOTPLockout(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	challengeID, err := cfg.newTOTPChallenge(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = useTOTPCode(ctx, cfg.database, user, code)
+	if errors.Is(err, errInvalidTOTPCode) || errors.Is(err, errReusedTOTPCode) {
+		recordErr := cfg.database.RecordTOTPChallengeFailure(ctx, challengeID)
+		if recordErr != nil {
+			return recordErr
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = cfg.database.UseTOTPChallenge(ctx, database.UseTOTPChallengeParams{
+		ID:          challengeID,
+		MaxAttempts: totpMaxFailures,
+	})
+	return err
+}
+
+type consentScope struct {
+	Name        string
+	Description string
+}
+
+type consentPage struct {
+	Error         string
+	ClientID      string
+	ClientName    string
+	RedirectURI   string
+	Scope         string
+	Scopes        []consentScope
+	State         string
+	CodeChallenge string
+	Email         string
+}
+
+func newConsentPage(req authorizeRequest) consentPage {
+	scopes := []consentScope{}
+	for _, scope := range req.Scopes {
+		scopes = append(scopes, consentScope{Name: scope, Description: oauthScopes[scope]})
+	}
+
+	return consentPage{
+		ClientID:      req.Client.ID,
+		ClientName:    req.Client.Name,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(req.Scopes, " "),
+		Scopes:        scopes,
+		State:         req.State,
+		CodeChallenge: req.CodeChallenge,
+	}
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<html>
+
+<body>
+	<h1>Chirpy</h1>
+	{{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+	{{if .ClientID}}
+	<p><strong>{{.ClientName}}</strong> would like to:</p>
+	<ul>
+		{{range .Scopes}}<li>{{.Description}} <code>{{.Name}}</code></li>{{end}}
+	</ul>
+	<form method="POST" action="/api/oauth/authorize">
+		<input type="hidden" name="response_type" value="code">
+		<input type="hidden" name="client_id" value="{{.ClientID}}">
+		<input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+		<input type="hidden" name="scope" value="{{.Scope}}">
+		<input type="hidden" name="state" value="{{.State}}">
+		<input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+		<input type="hidden" name="code_challenge_method" value="S256">
+		<p><label>Email <input type="email" name="email" value="{{.Email}}"></label></p>
+		<p><label>Password <input type="password" name="password"></label></p>
+		<p><label>Two-factor code (if enabled) <input type="text" name="totp_code" autocomplete="one-time-code"></label></p>
+		<button type="submit" name="decision" value="allow">Allow</button>
+		<button type="submit" name="decision" value="deny">Deny</button>
+	</form>
+	{{end}}
+</body>
+
+</html>
+`))
+
+func renderConsentPage(w http.ResponseWriter, r *http.Request, code int, page consentPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	err := consentTemplate.Execute(w, page)
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error rendering consent page", "error", err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+)
+
+const oauthAccessTokenTTL = time.Hour
+
+var errInvalidClient = errors.New("client authentication failed")
+
+// respondWithOAuthError writes an error in the format RFC 6749 section 5.2
+// expects from the token endpoint.
+func respondWithOAuthError(w http.ResponseWriter, r *http.Request, code int, oauthCode, description string, err error) {
+	type errorResponse struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+
+	if err != nil {
+		loggerFromContext(r.Context()).Info("Responding with OAuth error", "status", code, "error_code", oauthCode, "error", err)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, errorResponse{
+		Error:            oauthCode,
+		ErrorDescription: description,
+	})
+}
+
+// authenticateOAuthClient identifies the calling client from HTTP Basic auth
+// or the client_id and client_secret form fields. Public clients have no
+// secret and only need to name themselves.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	client, err := cfg.database.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, errInvalidClient
+	}
+
+	if client.SecretHash.Valid {
+		given := auth.HashToken(clientSecret)
+		if subtle.ConstantTimeCompare([]byte(given), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errInvalidClient
+		}
+	}
+
+	return client, nil
+}
+
+func (cfg *apiConfig) oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+		Scope       string `json:"scope"`
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_request", "Couldn't parse form", nil)
+		return
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusUnauthorized, "invalid_client", "Client authentication failed", nil)
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "unsupported_grant_type", "Only the authorization_code grant is supported", nil)
+		return
+	}
+
+	code, err := cfg.database.UseOAuthAuthorizationCode(r.Context(), database.UseOAuthAuthorizationCodeParams{
+		CodeHash: auth.HashToken(r.PostForm.Get("code")),
+		ClientID: client.ID,
+	})
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Authorization code is invalid, expired or already used", nil)
+		return
+	}
+
+	if r.PostForm.Get("redirect_uri") != code.RedirectUri {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Redirect URI doesn't match the authorization request", nil)
+		return
+	}
+
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "PKCE verification failed", nil)
+		return
+	}
+
+	// The code may have been issued before the user was suspended.
+	err = cfg.checkNotSuspended(r.Context(), code.UserID)
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Authorization code is invalid, expired or already used", err)
+		return
+	}
+
+	scopes := auth.ParseScope(code.Scope)
+	accessToken, tokenID, err := auth.MakeOAuthAccessToken(code.UserID, client.ID, scopes, cfg.secret, oauthAccessTokenTTL)
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusInternalServerError, "server_error", "Couldn't create access token", err)
+		return
+	}
+
+	err = cfg.database.CreateOAuthAccessToken(r.Context(), database.CreateOAuthAccessTokenParams{
+		ID:        tokenID,
+		ClientID:  client.ID,
+		UserID:    code.UserID,
+		Scope:     code.Scope,
+		ExpiresAt: time.Now().UTC().Add(oauthAccessTokenTTL),
+	})
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusInternalServerError, "server_error", "Couldn't save access token", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, response{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oauthAccessTokenTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+// oauthIntrospectHandler implements RFC 7662. Only confidential clients may
+// introspect, and only tokens that were issued to them.
+func (cfg *apiConfig) oauthIntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		IssuedAt  int64  `json:"iat,omitempty"`
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_request", "Couldn't parse form", nil)
+		return
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil || !client.SecretHash.Valid {
+		respondWithOAuthError(w, r, http.StatusUnauthorized, "invalid_client", "Client authentication failed", nil)
+		return
+	}
+
+	claims, err := auth.ValidateAccessToken(r.PostForm.Get("token"), cfg.secret)
+	if err != nil || claims.ClientID != client.ID {
+		respondWithJSON(w, http.StatusOK, response{Active: false})
+		return
+	}
+
+	stored, err := cfg.database.GetOAuthAccessToken(r.Context(), claims.TokenID)
+	if err != nil || stored.RevokedAt.Valid || stored.ClientID != client.ID {
+		respondWithJSON(w, http.StatusOK, response{Active: false})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Active:    true,
+		Scope:     stored.Scope,
+		ClientID:  stored.ClientID,
+		Subject:   stored.UserID.String(),
+		TokenType: "Bearer",
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+	})
+}
+
+// oauthRevokeHandler implements RFC 7009. Unknown or already revoked tokens
+// still get a 200 so clients can't probe for valid tokens.
+func (cfg *apiConfig) oauthRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_request", "Couldn't parse form", nil)
+		return
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusUnauthorized, "invalid_client", "Client authentication failed", nil)
+		return
+	}
+
+	claims, err := auth.ValidateAccessToken(r.PostForm.Get("token"), cfg.secret)
+	if err == nil && claims.ClientID == client.ID {
+		err = cfg.database.RevokeOAuthAccessToken(r.Context(), database.RevokeOAuthAccessTokenParams{
+			ID:       claims.TokenID,
+			ClientID: client.ID,
+		})
+		if err != nil {
+			respondWithOAuthError(w, r, http.StatusServiceUnavailable, "temporarily_unavailable", "Couldn't revoke token", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/mailer"
+)
+
+const passwordResetTTL = time.Hour
+
+func (cfg *apiConfig) sendPasswordResetEmail(ctx context.Context, user database.User) error {
+	token, err := auth.MakeOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.database.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password for your Chirpy account.\n\n"+
+				"Use this token with POST %s/api/password-reset/confirm within the next hour:\n\n%s\n\n"+
+				"If this wasn't you, you can ignore this email.\n",
+			cfg.baseURL, token,
+		),
+	})
+}
+
+func (cfg *apiConfig) passwordResetRequestHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		return
+	}
+
+	// Respond the same way, and just as quickly, whether or not the account
+	// exists so this endpoint can't be used to discover registered email
+	// addresses.
+	cfg.runInBackground(r.Context(), func(ctx context.Context) {
+		user, err := cfg.database.GetUserByEmail(ctx, params.Email)
+		if err != nil {
+			return
+		}
+
+		err = cfg.sendPasswordResetEmail(ctx, user)
+		if err != nil {
+			loggerFromContext(ctx).Error("Error sending password reset email", "user_id", user.ID, "error", err)
+		}
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) passwordResetConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		return
+	}
+
+	if params.Token == "" {
+		respondWithError(w, r, http.StatusBadRequest, "Missing reset token", nil)
+		return
+	}
+
+	if !cfg.validatePassword(w, r, params.Password) {
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't hash password", err)
+		return
+	}
+
+	resetToken, err := cfg.database.UsePasswordResetToken(r.Context(), auth.HashToken(params.Token))
+	if err != nil {
+		cfg.audit(r, auditEvent{
+			Action:  auditPasswordReset,
+			Outcome: auditFailure,
+			Detail:  "invalid or expired reset token",
+		})
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired reset token", err)
+		return
+	}
+
+	err = cfg.database.SetUserPassword(r.Context(), database.SetUserPasswordParams{
+		ID:             resetToken.UserID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update password", err)
+		return
+	}
+
+	err = cfg.database.InvalidatePasswordResetTokens(r.Context(), resetToken.UserID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't invalidate reset tokens", err)
+		return
+	}
+
+	err = cfg.database.RevokeAllRefreshTokensForUser(r.Context(), resetToken.UserID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	cfg.audit(r, auditEvent{
+		Action:   auditPasswordReset,
+		Outcome:  auditSuccess,
+		ActorID:  resetToken.UserID,
+		TargetID: resetToken.UserID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/entitlements"
+)
+
+const (
+	polkaTimestampHeader  = "X-Polka-Timestamp"
+	polkaSignatureHeader  = "X-Polka-Signature"
+	polkaWebhookTolerance = 5 * time.Minute
+	maxWebhookBodyBytes   = 1 << 20
+)
+
+var (
+	errInvalidAPIKey         = errors.New("API key doesn't match")
+	errDuplicateWebhookEvent = errors.New("webhook event was already processed")
+)
+
+// authenticatePolka accepts a webhook signed with any of the active Polka
+// secrets. Deployments that haven't configured secrets yet fall back to the
+// static API key, compared in constant time.
+func (cfg *apiConfig) authenticatePolka(r *http.Request, body []byte) error {
+	if len(cfg.polkaWebhookSecrets) > 0 {
+		return auth.VerifyWebhookSignature(
+			cfg.polkaWebhookSecrets,
+			r.Header.Get(polkaTimestampHeader),
+			r.Header.Get(polkaSignatureHeader),
+			body,
+			time.Now(),
+			polkaWebhookTolerance,
+		)
+	}
+
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		return err
+	}
+
+	if cfg.polka_key == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.polka_key)) != 1 {
+		return errInvalidAPIKey
+	}
+
+	return nil
+}
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID           string     `json:"user_id"`
+		Plan             string     `json:"plan"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+// polkaEventActions maps the Polka events Chirpy handles to the audit action
+// they're recorded under. Anything else is acknowledged and ignored.
+var polkaEventActions = map[string]string{
+	"user.upgraded":       auditChirpyRedUpgrade,
+	"user.downgraded":     auditSubscriptionDowngrade,
+	"user.payment_failed": auditSubscriptionPaymentFailed,
+	"user.canceled":       auditSubscriptionCancel,
+}
+
+// applyPolkaEvent updates the user's subscription for a Polka billing event
+// with q. For user.upgraded it returns the activated subscription, for the
+// caller to announce once the transaction commits.
+func applyPolkaEvent(ctx context.Context, q *database.Queries, userID uuid.UUID, event polkaEvent) (database.Subscription, error) {
+	switch event.Event {
+	case "user.upgraded":
+		plan := event.Data.Plan
+		if plan == "" {
+			plan = entitlements.PlanChirpyRed
+		}
+		periodEnd := time.Time{}
+		if event.Data.CurrentPeriodEnd != nil {
+			periodEnd = *event.Data.CurrentPeriodEnd
+		}
+		return activateSubscriptionWith(ctx, q, userID, plan, periodEnd)
+	case "user.downgraded":
+		return database.Subscription{}, changeSubscriptionStatusWith(ctx, q, userID, subscriptionExpired)
+	case "user.payment_failed":
+		return database.Subscription{}, changeSubscriptionStatusWith(ctx, q, userID, subscriptionPastDue)
+	case "user.canceled":
+		return database.Subscription{}, changeSubscriptionStatusWith(ctx, q, userID, subscriptionCanceled)
+	default:
+		return database.Subscription{}, nil
+	}
+}
+
+// webhookError is a webhook that couldn't be processed, with the response
+// the sender should get.
+type webhookError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+func (e *webhookError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *webhookError) Unwrap() error {
+	return e.Err
+}
+
+// processPolkaWebhook applies an authenticated Polka webhook body. It's
+// shared by the webhook endpoint and admin replays, and returns the parsed
+// event and the status to log it under.
+func (cfg *apiConfig) processPolkaWebhook(r *http.Request, body []byte) (polkaEvent, string, *webhookError) {
+	params := polkaEvent{}
+	err := json.Unmarshal(body, &params)
+	if err != nil {
+		return params, webhookFailed, &webhookError{http.StatusInternalServerError, "Couldn't decode the provided parameters", err}
+	}
+
+	action, ok := polkaEventActions[params.Event]
+	if !ok {
+		return params, webhookIgnored, nil
+	}
+
+	userUUID, err := uuid.Parse(params.Data.UserID)
+	if err != nil {
+		return params, webhookFailed, &webhookError{http.StatusBadRequest, "Couldn't parse user UUID", err}
+	}
+
+	// Signed webhooks must carry an event ID so retries and replays are only
+	// applied once. Unsigned legacy webhooks use one if they have it.
+	if params.ID == "" && len(cfg.polkaWebhookSecrets) > 0 {
+		return params, webhookFailed, &webhookError{http.StatusBadRequest, "Webhook event ID is required", nil}
+	}
+
+	// The event is claimed in the same transaction that applies it, so a
+	// failure releases it for Polka's retry or a replay instead of it being
+	// dropped as a duplicate.
+	var sub database.Subscription
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		if params.ID != "" {
+			claimed, err := q.ClaimWebhookEvent(r.Context(), database.ClaimWebhookEventParams{
+				ID:    params.ID,
+				Event: params.Event,
+			})
+			if err != nil {
+				return &webhookError{http.StatusInternalServerError, "Couldn't record webhook event", err}
+			}
+			if claimed == 0 {
+				return errDuplicateWebhookEvent
+			}
+		}
+
+		var err error
+		sub, err = applyPolkaEvent(r.Context(), q, userUUID, params)
+		return err
+	})
+	if errors.Is(err, errDuplicateWebhookEvent) {
+		return params, webhookDuplicate, nil
+	}
+	var whErr *webhookError
+	if errors.As(err, &whErr) {
+		return params, webhookFailed, whErr
+	}
+	if err != nil {
+		cfg.audit(r, auditEvent{
+			Action:   action,
+			Outcome:  auditFailure,
+			TargetID: userUUID,
+			Detail:   err.Error(),
+		})
+
+		if errors.Is(err, errSubscriptionUser) || errors.Is(err, errNoSubscription) {
+			return params, webhookFailed, &webhookError{http.StatusNotFound, "Couldn't find user subscription", err}
+		}
+		return params, webhookFailed, &webhookError{http.StatusInternalServerError, "Couldn't update subscription", err}
+	}
+
+	cfg.audit(r, auditEvent{
+		Action:   action,
+		Outcome:  auditSuccess,
+		TargetID: userUUID,
+		Detail:   "polka " + params.ID,
+	})
+
+	if params.Event == "user.upgraded" {
+		cfg.emitUserUpgraded(r.Context(), userUUID, sub)
+	}
+
+	return params, webhookProcessed, nil
+}
+
+func (cfg *apiConfig) chirpyRedHandler(w http.ResponseWriter, r *http.Request) {
+	// The signature covers the exact bytes Polka sent, so read them before
+	// decoding anything.
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't read request body", err)
+		return
+	}
+
+	logID := cfg.logInboundWebhook(r, webhookSourcePolka, body)
+
+	err = cfg.authenticatePolka(r, body)
+	if err != nil {
+		cfg.finishInboundWebhook(r.Context(), webhookSourcePolka, logID, "", "", webhookRejected, err)
+		respondWithError(w, r, http.StatusUnauthorized, "Webhook authentication failed", err)
+		return
+	}
+	cfg.markInboundWebhookAuthenticated(r.Context(), logID)
+
+	event, status, whErr := cfg.processPolkaWebhook(r, body)
+	if whErr != nil {
+		cfg.finishInboundWebhook(r.Context(), webhookSourcePolka, logID, event.ID, event.Event, status, whErr)
+		respondWithError(w, r, whErr.Code, whErr.Message, whErr.Err)
+		return
+	}
+
+	cfg.finishInboundWebhook(r.Context(), webhookSourcePolka, logID, event.ID, event.Event, status, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
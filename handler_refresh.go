@@ -14,13 +14,30 @@ func (cfg *apiConfig) refreshUserToken(w http.ResponseWriter, r *http.Request) {
 
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Couldn't find token", err)
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't find token", err)
 		return
 	}
 
 	user, err := cfg.database.GetUserFromRefreshToken(r.Context(), refreshToken)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get user for refresh token", err)
+		cfg.audit(r, auditEvent{
+			Action:  auditTokenRefresh,
+			Outcome: auditFailure,
+			Detail:  "invalid refresh token",
+		})
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't get user for refresh token", err)
+		return
+	}
+
+	if user.SuspendedAt.Valid {
+		cfg.audit(r, auditEvent{
+			Action:   auditTokenRefresh,
+			Outcome:  auditFailure,
+			ActorID:  user.ID,
+			TargetID: user.ID,
+			Detail:   "account suspended",
+		})
+		respondWithError(w, r, http.StatusForbidden, "This account has been suspended", nil)
 		return
 	}
 
@@ -30,10 +47,17 @@ func (cfg *apiConfig) refreshUserToken(w http.ResponseWriter, r *http.Request) {
 		time.Hour,
 	)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate token", err)
 		return
 	}
 
+	cfg.audit(r, auditEvent{
+		Action:   auditTokenRefresh,
+		Outcome:  auditSuccess,
+		ActorID:  user.ID,
+		TargetID: user.ID,
+	})
+
 	respondWithJSON(w, http.StatusOK, response{
 		Token: accessToken,
 	})
@@ -42,15 +66,27 @@ func (cfg *apiConfig) refreshUserToken(w http.ResponseWriter, r *http.Request) {
 func (cfg *apiConfig) revokeUserToken(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Couldn't find token", err)
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't find token", err)
 		return
 	}
 
-	_, err = cfg.database.RevokeRefreshToken(r.Context(), refreshToken)
+	revoked, err := cfg.database.RevokeRefreshToken(r.Context(), refreshToken)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Couldn't revoke session", err)
+		cfg.audit(r, auditEvent{
+			Action:  auditTokenRevoke,
+			Outcome: auditFailure,
+			Detail:  "unknown refresh token",
+		})
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't revoke session", err)
 		return
 	}
 
+	cfg.audit(r, auditEvent{
+		Action:   auditTokenRevoke,
+		Outcome:  auditSuccess,
+		ActorID:  revoked.UserID,
+		TargetID: revoked.UserID,
+	})
+
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+)
+
+const (
+	recoveryCodeCount = 10
+	// totpChallengeTTL is how long a user has to enter their second factor
+	// after their password.
+	totpChallengeTTL = 5 * time.Minute
+	// totpMaxFailures wrong codes within totpLockoutWindow, counted across
+	// all of a user's challenges, lock them out of the second factor until
+	// the oldest failure falls out of the window.
+	totpMaxFailures   = 5
+	totpLockoutWindow = 15 * time.Minute
+)
+
+var (
+	errTOTPLockedOut       = errors.New("too many failed two-factor attempts")
+	errTOTPChallengeUsed   = errors.New("totp challenge already used")
+	errInvalidTOTPCode     = errors.New("invalid totp code")
+	errReusedTOTPCode      = errors.New("reused totp code")
+	errInvalidRecoveryCode = errors.New("invalid recovery code")
+)
+
+type totpChallengeResponse struct {
+	TOTPRequired   bool   `json:"totp_required"`
+	ChallengeToken string `json:"challenge_token"`
+}
+
+func (cfg *apiConfig) totpEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+
+	userID, err := cfg.authenticateFirstParty(r)
+	if err != nil {
+		respondWithAuthError(w, r, err)
+		return
+	}
+
+	user, err := cfg.database.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	if user.TotpEnabled {
+		respondWithError(w, r, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't generate TOTP secret", err)
+		return
+	}
+
+	err = cfg.database.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save TOTP secret", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI("Chirpy", user.Email, secret),
+	})
+}
+
+func (cfg *apiConfig) totpConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	type response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userID, err := cfg.authenticateFirstParty(r)
+	if err != nil {
+		respondWithAuthError(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		return
+	}
+
+	user, err := cfg.database.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	if user.TotpEnabled {
+		respondWithError(w, r, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	if !user.TotpSecret.Valid {
+		respondWithError(w, r, http.StatusBadRequest, "Two-factor enrollment hasn't been started", nil)
+		return
+	}
+
+	step, ok := auth.MatchTOTP(user.TotpSecret.String, params.Code, time.Now(), lastTOTPStep(user))
+	if !ok {
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid TOTP code", nil)
+		return
+	}
+
+	// The code that confirmed enrollment can't then be used to log in.
+	_, err = cfg.database.SetTOTPLastStep(r.Context(), database.SetTOTPLastStepParams{
+		ID:           user.ID,
+		TotpLastStep: sql.NullInt64{Int64: step, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't record TOTP code", err)
+		return
+	}
+
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't generate recovery codes", err)
+		return
+	}
+
+	err = cfg.database.DeleteRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't clear old recovery codes", err)
+		return
+	}
+
+	for _, code := range codes {
+		err = cfg.database.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't save recovery codes", err)
+			return
+		}
+	}
+
+	err = cfg.database.EnableTOTP(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		RecoveryCodes: codes,
+	})
+}
+
+func (cfg *apiConfig) loginTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode provided parameters", err)
+		return
+	}
+
+	userID, challengeID, err := auth.ValidateTOTPChallenge(params.ChallengeToken, cfg.secret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+
+	challenge, err := cfg.database.GetTOTPChallenge(r.Context(), database.GetTOTPChallengeParams{
+		ID:     challengeID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't check challenge token", err)
+		return
+	}
+	if challenge.UsedAt.Valid || challenge.FailedAttempts >= totpMaxFailures {
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired challenge token", nil)
+		return
+	}
+
+	user, err := cfg.database.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+
+	if !user.TotpEnabled || !user.TotpSecret.Valid {
+		respondWithError(w, r, http.StatusUnauthorized, "Two-factor authentication isn't enabled", nil)
+		return
+	}
+
+	// The user passed the suspension check before getting their challenge,
+	// but may have been suspended since.
+	if cfg.rejectSuspendedLogin(w, r, user, "totp") {
+		return
+	}
+
+	err = cfg.checkTOTPLockout(r.Context(), user.ID)
+	if errors.Is(err, errTOTPLockedOut) {
+		cfg.audit(r, auditEvent{
+			Action:   auditLogin,
+			Outcome:  auditFailure,
+			ActorID:  user.ID,
+			TargetID: user.ID,
+			Detail:   err.Error(),
+		})
+		respondWithError(w, r, http.StatusTooManyRequests, "Too many failed attempts, try again later", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't check failed attempts", err)
+		return
+	}
+
+	method := "totp"
+	switch {
+	case params.Code != "":
+	case params.RecoveryCode != "":
+		method = "recovery code"
+	default:
+		respondWithError(w, r, http.StatusBadRequest, "A TOTP code or recovery code is required", nil)
+		return
+	}
+
+	// The challenge and the second factor are used up together, so a
+	// recovery code is never spent on a challenge that turns out to have
+	// been used by a racing request.
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		used, err := q.UseTOTPChallenge(r.Context(), database.UseTOTPChallengeParams{
+			ID:          challengeID,
+			MaxAttempts: totpMaxFailures,
+		})
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errTOTPChallengeUsed
+		}
+
+		if params.Code != "" {
+			return useTOTPCode(r.Context(), q, user, params.Code)
+		}
+
+		_, err = q.UseRecoveryCode(r.Context(), database.UseRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(params.RecoveryCode),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidRecoveryCode
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, errTOTPChallengeUsed):
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired challenge token", nil)
+		return
+	case errors.Is(err, errInvalidTOTPCode), errors.Is(err, errReusedTOTPCode):
+		cfg.failTOTPChallenge(w, r, user, challengeID, err.Error(), "Invalid TOTP code")
+		return
+	case errors.Is(err, errInvalidRecoveryCode):
+		cfg.failTOTPChallenge(w, r, user, challengeID, err.Error(), "Invalid recovery code")
+		return
+	case err != nil:
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't check second factor", err)
+		return
+	}
+
+	cfg.finishLogin(w, r, user, method)
+}
+
+// newTOTPChallenge records a challenge for a user who has passed their
+// first factor, against which wrong second factors are counted.
+func (cfg *apiConfig) newTOTPChallenge(ctx context.Context, userID uuid.UUID) (uuid.UUID, error) {
+	// Challenges are kept through the lockout window since their failed
+	// attempts count towards it.
+	err := cfg.database.DeleteStaleTOTPChallenges(ctx, database.DeleteStaleTOTPChallengesParams{
+		UserID:    userID,
+		CreatedAt: time.Now().UTC().Add(-totpLockoutWindow),
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("Error deleting stale TOTP challenges", "user_id", userID, "error", err)
+	}
+
+	challengeID := uuid.New()
+	err = cfg.database.CreateTOTPChallenge(ctx, database.CreateTOTPChallengeParams{
+		ID:        challengeID,
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(totpChallengeTTL),
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return challengeID, nil
+}
+
+// checkTOTPLockout returns errTOTPLockedOut once the user has entered too
+// many wrong second factors within the lockout window.
+func (cfg *apiConfig) checkTOTPLockout(ctx context.Context, userID uuid.UUID) error {
+	failures, err := cfg.database.CountRecentTOTPFailures(ctx, database.CountRecentTOTPFailuresParams{
+		UserID:    userID,
+		CreatedAt: time.Now().UTC().Add(-totpLockoutWindow),
+	})
+	if err != nil {
+		return err
+	}
+	if failures >= totpMaxFailures {
+		return errTOTPLockedOut
+	}
+	return nil
+}
+
+// useTOTPCode accepts a code from the user's authenticator app at most once,
+// returning errInvalidTOTPCode or errReusedTOTPCode if it's rejected.
+func useTOTPCode(ctx context.Context, q *database.Queries, user database.User, code string) error {
+	step, ok := auth.MatchTOTP(user.TotpSecret.String, code, time.Now(), lastTOTPStep(user))
+	if !ok {
+		return errInvalidTOTPCode
+	}
+
+	// Only one request can move the last step forward, so a code can't be
+	// replayed even by a request racing this one.
+	updated, err := q.SetTOTPLastStep(ctx, database.SetTOTPLastStepParams{
+		ID:           user.ID,
+		TotpLastStep: sql.NullInt64{Int64: step, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return errReusedTOTPCode
+	}
+	return nil
+}
+
+// failTOTPChallenge counts a wrong second factor against the challenge and,
+// through it, the user's lockout, then rejects the request.
+func (cfg *apiConfig) failTOTPChallenge(w http.ResponseWriter, r *http.Request, user database.User, challengeID uuid.UUID, detail, msg string) {
+	err := cfg.database.RecordTOTPChallengeFailure(r.Context(), challengeID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't record failed attempt", err)
+		return
+	}
+
+	cfg.audit(r, auditEvent{
+		Action:   auditLogin,
+		Outcome:  auditFailure,
+		ActorID:  user.ID,
+		TargetID: user.ID,
+		Detail:   detail,
+	})
+	respondWithError(w, r, http.StatusUnauthorized, msg, nil)
+}
+
+// lastTOTPStep is the time step of the last code the user logged in with,
+// or -1 if there hasn't been one.
+func lastTOTPStep(user database.User) int64 {
+	if !user.TotpLastStep.Valid {
+		return -1
+	}
+	return user.TotpLastStep.Int64
+}
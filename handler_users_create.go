@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"net/mail"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +18,8 @@ type User struct {
 	UpdatedAt        time.Time `json:"updated_at"`
 	Email            string    `json:"email"`
 	IsChirpyRed      bool      `json:"is_chirpy_red"`
+	EmailVerified    bool      `json:"email_verified"`
+	Role             string    `json:"role"`
 	ExpiresInSeconds int       `json:"expires_in_seconds"`
 }
 
@@ -33,13 +37,22 @@ func (cfg *apiConfig) createNewUser(w http.ResponseWriter, r *http.Request) {
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		return
+	}
+
+	if !validEmail(params.Email) {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid email address", nil)
+		return
+	}
+
+	if !cfg.validatePassword(w, r, params.Password, params.Email) {
 		return
 	}
 
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
 	}
 
@@ -50,16 +63,34 @@ func (cfg *apiConfig) createNewUser(w http.ResponseWriter, r *http.Request) {
 
 	user, err := cfg.database.CreateUser(r.Context(), userArgs)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create user", err)
+		return
 	}
 
+	// A slow mail server shouldn't hold up signing up.
+	cfg.runInBackground(r.Context(), func(ctx context.Context) {
+		err := cfg.sendVerificationEmail(ctx, user)
+		if err != nil {
+			loggerFromContext(ctx).Error("Error sending verification email", "user_id", user.ID, "error", err)
+		}
+	})
+
 	respondWithJSON(w, http.StatusCreated, response{
 		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
+			ID:            user.ID,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+			Email:         user.Email,
+			IsChirpyRed:   user.IsChirpyRed,
+			EmailVerified: user.EmailVerified,
+			Role:          user.Role,
 		},
 	})
 }
+
+// validEmail reports whether email is a bare address, like a@example.com,
+// without a display name or angle brackets.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
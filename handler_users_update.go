@@ -1,33 +1,34 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/jzetterman/chirpy/internal/auth"
 	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/lib/pq"
 )
 
+// userUpdateHandler applies a partial update: only the fields present in the
+// body change. Any change requires the current password, and changing the
+// password revokes every existing session and returns a fresh token pair.
 func (cfg *apiConfig) userUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
 	}
 
 	type response struct {
 		User
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	userID, err := cfg.authenticateFirstParty(r)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unable to verify token", err)
+		respondWithAuthError(w, r, err)
 		return
 	}
 
@@ -35,80 +36,128 @@ func (cfg *apiConfig) userUpdateHandler(w http.ResponseWriter, r *http.Request)
 	params := parameters{}
 	err = decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Unable to hash password", err)
+	if params.Email == nil && params.Password == nil {
+		respondWithError(w, r, http.StatusBadRequest, "Nothing to update", nil)
 		return
 	}
 
-	user, err := cfg.database.UpdateUser(r.Context(), database.UpdateUserParams{
-		ID:             userID,
-		Email:          params.Email,
-		HashedPassword: hashedPassword,
-	})
+	previous, err := cfg.database.GetUserByID(r.Context(), userID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't update the user", err)
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
-	})
-}
+	err = auth.CheckPasswordHash(previous.HashedPassword, params.CurrentPassword)
+	if err != nil {
+		cfg.auditUserUpdate(r, previous.ID, params.Email != nil, params.Password != nil, auditFailure, "incorrect current password")
+		respondWithError(w, r, http.StatusForbidden, "Current password is incorrect", err)
+		return
+	}
 
-func (cfg *apiConfig) chirpyRedHandler(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
+	updateArgs := database.UpdateUserParams{
+		ID: userID,
 	}
 
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "API key not valid", err)
-		return
+	if params.Email != nil {
+		if *params.Email == "" {
+			respondWithError(w, r, http.StatusBadRequest, "Email can't be empty", nil)
+			return
+		}
+		updateArgs.Email = sql.NullString{String: *params.Email, Valid: true}
 	}
 
-	if apiKey != cfg.polka_key {
-		respondWithError(w, http.StatusUnauthorized, "API key not valid", err)
-		return
+	if params.Password != nil {
+		email := previous.Email
+		if params.Email != nil {
+			email = *params.Email
+		}
+
+		if !cfg.validatePassword(w, r, *params.Password, email) {
+			return
+		}
+
+		hashedPassword, err := auth.HashPassword(*params.Password)
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Unable to hash password", err)
+			return
+		}
+		updateArgs.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	user, err := cfg.database.UpdateUser(r.Context(), updateArgs)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			respondWithError(w, r, http.StatusConflict, "Email is already in use", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update the user", err)
 		return
 	}
 
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
+	emailDetail := ""
+	if params.Email != nil {
+		emailDetail = previous.Email + " -> " + user.Email
 	}
+	cfg.auditUserUpdate(r, user.ID, params.Email != nil, params.Password != nil, auditSuccess, emailDetail)
 
-	userUUID, err := uuid.Parse(params.Data.UserID)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Couldn't parse user UUID", err)
-		return
+	if user.Email != previous.Email {
+		err = cfg.sendVerificationEmail(r.Context(), user)
+		if err != nil {
+			loggerFromContext(r.Context()).Error("Error sending verification email", "user_id", user.ID, "error", err)
+		}
 	}
 
-	err = cfg.database.UpgradeToChirpyRed(r.Context(), userUUID)
-	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't upgrade to Chirpy Red", err)
+	if params.Password != nil {
+		err = cfg.database.RevokeAllRefreshTokensForUser(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+			return
+		}
+
+		cfg.respondWithTokens(w, r, user)
 		return
 	}
 
-	respondWithJSON(w, http.StatusNoContent, nil)
+	respondWithJSON(w, http.StatusOK, response{
+		User: User{
+			ID:            user.ID,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+			Email:         user.Email,
+			IsChirpyRed:   user.IsChirpyRed,
+			EmailVerified: user.EmailVerified,
+			Role:          user.Role,
+		},
+	})
+}
+
+// auditUserUpdate records the email and password changes a user asked for.
+func (cfg *apiConfig) auditUserUpdate(r *http.Request, userID uuid.UUID, emailChanged, passwordChanged bool, outcome, detail string) {
+	if emailChanged {
+		cfg.audit(r, auditEvent{
+			Action:   auditEmailChange,
+			Outcome:  outcome,
+			ActorID:  userID,
+			TargetID: userID,
+			Detail:   detail,
+		})
+	}
+	if passwordChanged {
+		passwordDetail := ""
+		if outcome == auditFailure {
+			passwordDetail = detail
+		}
+		cfg.audit(r, auditEvent{
+			Action:   auditPasswordChange,
+			Outcome:  outcome,
+			ActorID:  userID,
+			TargetID: userID,
+			Detail:   passwordDetail,
+		})
+	}
 }
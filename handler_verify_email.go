@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/mailer"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token, err := auth.MakeEmailVerificationToken(user.ID, user.Email, cfg.secret, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := cfg.baseURL + "/api/verify-email?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body:    fmt.Sprintf("Welcome to Chirpy!\n\nConfirm your email address by opening this link within 24 hours:\n\n%s\n", link),
+	})
+}
+
+func (cfg *apiConfig) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, r, http.StatusBadRequest, "Missing verification token", nil)
+		return
+	}
+
+	userID, email, err := auth.ValidateEmailVerificationToken(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired verification token", err)
+		return
+	}
+
+	user, err := cfg.database.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{
+		ID:    userID,
+		Email: email,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid or expired verification token", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+	})
+}
+
+func (cfg *apiConfig) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticateFirstParty(r)
+	if err != nil {
+		respondWithAuthError(w, r, err)
+		return
+	}
+
+	user, err := cfg.database.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	if user.EmailVerified {
+		respondWithError(w, r, http.StatusConflict, "Email address is already verified", nil)
+		return
+	}
+
+	err = cfg.sendVerificationEmail(r.Context(), user)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/tracing"
+)
+
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+func newWebhookSubscription(subscription database.WebhookSubscription) WebhookSubscription {
+	return WebhookSubscription{
+		ID:        subscription.ID,
+		CreatedAt: subscription.CreatedAt,
+		URL:       subscription.Url,
+		Events:    subscription.Events,
+	}
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EventID        uuid.UUID  `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus int32      `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+func newWebhookDelivery(delivery database.WebhookDelivery) WebhookDelivery {
+	result := WebhookDelivery{
+		ID:             delivery.ID,
+		CreatedAt:      delivery.CreatedAt,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus.Int32,
+		LastError:      delivery.LastError.String,
+	}
+	if delivery.Status == deliveryPending || delivery.Status == deliveryRetrying {
+		result.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastAttemptAt.Valid {
+		result.LastAttemptAt = &delivery.LastAttemptAt.Time
+	}
+	if delivery.DeliveredAt.Valid {
+		result.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return result
+}
+
+// webhookOwner identifies who is managing webhook subscriptions: a
+// confidential OAuth client using HTTP Basic auth, or a user with a
+// first-party access token.
+func (cfg *apiConfig) webhookOwner(r *http.Request) (_ uuid.NullUUID, _ sql.NullString, err error) {
+	ctx, span := tracing.Start(r.Context(), "auth.webhook_owner")
+	defer func() { tracing.End(span, err) }()
+	r = r.WithContext(ctx)
+
+	if _, _, ok := r.BasicAuth(); ok {
+		client, err := cfg.authenticateOAuthClient(r)
+		if err != nil || !client.SecretHash.Valid {
+			return uuid.NullUUID{}, sql.NullString{}, errInvalidClient
+		}
+		return uuid.NullUUID{}, sql.NullString{String: client.ID, Valid: true}, nil
+	}
+
+	userID, err := cfg.authenticateFirstParty(r)
+	if err != nil {
+		return uuid.NullUUID{}, sql.NullString{}, err
+	}
+
+	return uuid.NullUUID{UUID: userID, Valid: true}, sql.NullString{}, nil
+}
+
+// respondWithWebhookOwnerError maps an error from webhookOwner to a response.
+func respondWithWebhookOwnerError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errAccountSuspended) {
+		respondWithAuthError(w, r, err)
+		return
+	}
+	respondWithError(w, r, http.StatusUnauthorized, "Couldn't authenticate", err)
+}
+
+// validWebhookURL accepts absolute https URLs. Plain http is only allowed on
+// the dev platform, for receivers running locally.
+func (cfg *apiConfig) validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.User != nil {
+		return false
+	}
+
+	return u.Scheme == "https" || (u.Scheme == "http" && cfg.platform == "dev")
+}
+
+func (cfg *apiConfig) webhookSubscriptionCreateHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	ownerID, clientID, err := cfg.webhookOwner(r)
+	if err != nil {
+		respondWithWebhookOwnerError(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode the provided parameters", err)
+		return
+	}
+
+	if !cfg.validWebhookURL(params.URL) {
+		respondWithError(w, r, http.StatusBadRequest, "Webhook URL must be an absolute https URL", nil)
+		return
+	}
+
+	if len(params.Events) == 0 {
+		respondWithError(w, r, http.StatusBadRequest, "At least one event is required", nil)
+		return
+	}
+
+	for _, event := range params.Events {
+		scope, ok := webhookEvents[event]
+		if !ok {
+			respondWithError(w, r, http.StatusBadRequest, "Unknown event "+event, nil)
+			return
+		}
+		if clientID.Valid && scope == "" {
+			respondWithError(w, r, http.StatusBadRequest, "Apps can't subscribe to "+event, nil)
+			return
+		}
+	}
+
+	// Unlike other secrets this one is stored as is, since every delivery
+	// needs it to compute a signature. It's only ever shown here.
+	secret, err := auth.MakeOneTimeToken()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create webhook secret", err)
+		return
+	}
+
+	subscription, err := cfg.database.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+		OwnerID:  ownerID,
+		ClientID: clientID,
+		Url:      params.URL,
+		Secret:   secret,
+		Events:   params.Events,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create webhook subscription", err)
+		return
+	}
+
+	result := newWebhookSubscription(subscription)
+	result.Secret = secret
+	respondWithJSON(w, http.StatusCreated, result)
+}
+
+func (cfg *apiConfig) webhookSubscriptionsListHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, clientID, err := cfg.webhookOwner(r)
+	if err != nil {
+		respondWithWebhookOwnerError(w, r, err)
+		return
+	}
+
+	dbSubscriptions, err := cfg.database.ListWebhookSubscriptionsByOwner(r.Context(), database.ListWebhookSubscriptionsByOwnerParams{
+		OwnerID:  ownerID,
+		ClientID: clientID,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list webhook subscriptions", err)
+		return
+	}
+
+	subscriptions := []WebhookSubscription{}
+	for _, dbSubscription := range dbSubscriptions {
+		subscriptions = append(subscriptions, newWebhookSubscription(dbSubscription))
+	}
+
+	respondWithJSON(w, http.StatusOK, subscriptions)
+}
+
+// webhookSubscriptionFromPath loads the caller's subscription named by the
+// {subscriptionID} path value, writing an error response and returning false
+// if it can't.
+func (cfg *apiConfig) webhookSubscriptionFromPath(w http.ResponseWriter, r *http.Request) (database.WebhookSubscription, bool) {
+	ownerID, clientID, err := cfg.webhookOwner(r)
+	if err != nil {
+		respondWithWebhookOwnerError(w, r, err)
+		return database.WebhookSubscription{}, false
+	}
+
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid subscription ID", err)
+		return database.WebhookSubscription{}, false
+	}
+
+	subscription, err := cfg.database.GetWebhookSubscriptionForOwner(r.Context(), database.GetWebhookSubscriptionForOwnerParams{
+		ID:       subscriptionID,
+		OwnerID:  ownerID,
+		ClientID: clientID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find webhook subscription", err)
+		return database.WebhookSubscription{}, false
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get webhook subscription", err)
+		return database.WebhookSubscription{}, false
+	}
+
+	return subscription, true
+}
+
+func (cfg *apiConfig) webhookSubscriptionDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := cfg.webhookSubscriptionFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.database.DeleteWebhookSubscription(r.Context(), subscription.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete webhook subscription", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) webhookDeliveriesListHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := cfg.webhookSubscriptionFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 500 {
+			respondWithError(w, r, http.StatusBadRequest, "limit must be between 1 and 500", err)
+			return
+		}
+		limit = parsed
+	}
+
+	dbDeliveries, err := cfg.database.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		Limit:          int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list webhook deliveries", err)
+		return
+	}
+
+	deliveries := []WebhookDelivery{}
+	for _, dbDelivery := range dbDeliveries {
+		deliveries = append(deliveries, newWebhookDelivery(dbDelivery))
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// webhookRedeliverHandler moves a dead-lettered delivery back onto the queue
+// with a fresh set of attempts.
+func (cfg *apiConfig) webhookRedeliverHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := cfg.webhookSubscriptionFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	delivery, err := cfg.database.RequeueWebhookDelivery(r.Context(), database.RequeueWebhookDeliveryParams{
+		ID:             deliveryID,
+		SubscriptionID: subscription.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusConflict, "Only dead-lettered deliveries can be redelivered", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't requeue delivery", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, newWebhookDelivery(delivery))
+}
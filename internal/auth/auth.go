@@ -2,99 +2,117 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
-	if err != nil {
-		log.Printf("Error hashing password: %s", err)
-		return "", err
-	}
+const (
+	accessTokenIssuer   = "chirpy"
+	totpChallengeIssuer = "chirpy-totp-challenge"
+)
 
-	return string(hashedPassword), nil
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return makeToken(userID, tokenSecret, accessTokenIssuer, expiresIn)
 }
 
-func CheckPasswordHash(hash, password string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+// ValidateJWT only accepts first-party access tokens. Handlers that OAuth
+// clients may call should use ValidateAccessToken and check scopes instead.
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	claims, err := ValidateAccessToken(tokenString, tokenSecret)
 	if err != nil {
-		return err
+		return uuid.UUID{}, err
 	}
-	return nil
+
+	if claims.IsThirdParty() {
+		return uuid.UUID{}, errors.New("third-party access tokens aren't accepted here")
+	}
+
+	return claims.UserID, nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+// MakeTOTPChallenge issues the token handed out after a correct password for
+// users with two-factor authentication enabled. It carries its own issuer so
+// it can't be used as an access token, and challengeID as its jti so the
+// server can track attempts against it and use it only once.
+func MakeTOTPChallenge(userID, challengeID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
 	claims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy",
+		ID:        challengeID.String(),
+		Issuer:    totpChallengeIssuer,
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
 		Subject:   userID.String(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		log.Printf("Error signing token: %s", err)
-		return "", err
-	}
-
-	return signedToken, nil
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenSecret))
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+// ValidateTOTPChallenge returns the user and challenge ID a challenge token
+// was issued for.
+func ValidateTOTPChallenge(tokenString, tokenSecret string) (uuid.UUID, uuid.UUID, error) {
 	claims := &jwt.RegisteredClaims{}
-
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %s", token.Header["alg"])
 		}
 		return []byte(tokenSecret), nil
-	})
+	}, jwt.WithIssuer(totpChallengeIssuer))
+	if err != nil {
+		return uuid.UUID{}, uuid.UUID{}, err
+	}
 
+	userID, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.UUID{}, err
+		return uuid.UUID{}, uuid.UUID{}, err
 	}
 
-	if !token.Valid {
-		return uuid.UUID{}, err
+	challengeID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.UUID{}, uuid.UUID{}, errors.New("challenge token has no ID")
 	}
 
-	userID, err := uuid.Parse(claims.Subject)
+	return userID, challengeID, nil
+}
+
+func makeToken(userID uuid.UUID, tokenSecret, issuer string, expiresIn time.Duration) (string, error) {
+	claims := &jwt.RegisteredClaims{
+		Issuer:    issuer,
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		Subject:   userID.String(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signedToken, err := token.SignedString([]byte(tokenSecret))
 	if err != nil {
-		return uuid.UUID{}, err
+		return "", err
 	}
 
-	return userID, nil
+	return signedToken, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 
 	if authHeader == "" {
-		log.Printf("authorization header is missing")
 		return "", errors.New("authorization header is missing")
 	}
 
 	ok := strings.HasPrefix(authHeader, "Bearer ")
 	if !ok {
-		log.Printf("authorization header missing or malformed")
 		return "", errors.New("authorization header missing or malformed")
 	}
 
 	token := strings.SplitN(authHeader, " ", 2)
 
 	if len(token) != 2 || strings.TrimSpace(token[1]) == "" {
-		log.Printf("token was missing from authorization header")
 		return "", errors.New("token was missing from authorization header")
 	}
 
@@ -113,24 +131,38 @@ func MakeRefreshToken() (string, error) {
 	return encodedToken, nil
 }
 
+// MakeOneTimeToken returns a random token for single-use links such as
+// password resets. Store HashToken(token) rather than the token itself.
+func MakeOneTimeToken() (string, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", errors.New("Error generating one-time token")
+	}
+
+	return hex.EncodeToString(key), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 
 	if authHeader == "" {
-		log.Printf("authorization header is missing")
 		return "", errors.New("authorization header is missing")
 	}
 
 	ok := strings.HasPrefix(authHeader, "ApiKey ")
 	if !ok {
-		log.Printf("authorization header missing or malformed")
 		return "", errors.New("authorization header missing or malformed")
 	}
 
 	token := strings.SplitN(authHeader, " ", 2)
 
 	if len(token) != 2 || strings.TrimSpace(token[1]) == "" {
-		log.Printf("token was missing from authorization header")
 		return "", errors.New("token was missing from authorization header")
 	}
 
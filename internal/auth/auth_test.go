@@ -43,3 +43,27 @@ func TestExpiredJWTIsRejected(t *testing.T) {
 		t.Errorf("expected jwt.ErrTokenExpired, got %v", err)
 	}
 }
+
+func TestOneTimeTokensAreUniqueAndHashed(t *testing.T) {
+	first, err := MakeOneTimeToken()
+	if err != nil {
+		t.Fatalf("failed to create token: %s", err)
+	}
+
+	second, err := MakeOneTimeToken()
+	if err != nil {
+		t.Fatalf("failed to create token: %s", err)
+	}
+
+	if first == second {
+		t.Errorf("expected distinct tokens, got %s twice", first)
+	}
+
+	if HashToken(first) != HashToken(first) {
+		t.Errorf("expected hashing to be deterministic")
+	}
+
+	if HashToken(first) == first {
+		t.Errorf("expected hash to differ from the token")
+	}
+}
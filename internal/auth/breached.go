@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachedPasswords looks passwords up in a local copy of a breached
+// password corpus such as Have I Been Pwned's. The file holds one
+// "<SHA-1 hex>:<count>" entry per line sorted by hash, which is what the
+// HIBP downloader writes in single-file mode. Like the range API, lookups
+// find the block of entries sharing the first five hex characters of the
+// hash and compare the remaining suffix, so the file is never loaded into
+// memory.
+type BreachedPasswords struct {
+	path string
+}
+
+const hashPrefixLength = 5
+
+func OpenBreachedPasswords(path string) (*BreachedPasswords, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errors.New("breached password dataset must be a file")
+	}
+
+	return &BreachedPasswords{path: path}, nil
+}
+
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+
+	f, err := os.Open(b.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	size := info.Size()
+
+	// Binary search for the first line whose prefix is >= the one we want.
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineFrom(f, mid, size)
+		if err != nil {
+			return false, err
+		}
+		if start >= size || linePrefix(line) >= prefix {
+			hi = mid
+		} else {
+			lo = start + 1
+		}
+	}
+
+	start, _, err := lineFrom(f, lo, size)
+	if err != nil {
+		return false, err
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(f, start, size-start))
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line != "" {
+			if linePrefix(line) != prefix {
+				return false, nil
+			}
+
+			entry, _, _ := strings.Cut(strings.ToUpper(line), ":")
+			if entry[hashPrefixLength:] == suffix {
+				return true, nil
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// lineFrom returns the first line that starts at or after pos, along with
+// its offset. The offset is size when there is no such line.
+func lineFrom(f *os.File, pos, size int64) (int64, string, error) {
+	start := pos
+	if pos > 0 {
+		reader := bufio.NewReader(io.NewSectionReader(f, pos-1, size-pos+1))
+		skipped, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return size, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start = pos - 1 + int64(len(skipped))
+	}
+
+	if start >= size {
+		return size, "", nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(f, start, size-start))
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, "", err
+	}
+
+	return start, strings.TrimSpace(line), nil
+}
+
+func linePrefix(line string) string {
+	if len(line) < hashPrefixLength {
+		return strings.ToUpper(line)
+	}
+	return strings.ToUpper(line[:hashPrefixLength])
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEmailVerificationToken(t *testing.T) {
+	userID := uuid.New()
+	token, err := MakeEmailVerificationToken(userID, "user@example.com", "test", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create token: %s", err)
+	}
+
+	gotUserID, gotEmail, err := ValidateEmailVerificationToken(token, "test")
+	if err != nil {
+		t.Fatalf("failed to validate token: %s", err)
+	}
+
+	if gotUserID != userID || gotEmail != "user@example.com" {
+		t.Errorf("expected %v and user@example.com, got %v and %s", userID, gotUserID, gotEmail)
+	}
+
+	if _, err := ValidateJWT(token, "test"); err == nil {
+		t.Errorf("expected verification token to be rejected as an access token")
+	}
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMagicLinkToken(t *testing.T) {
+	userID := uuid.New()
+	token, linkID, err := MakeMagicLinkToken(userID, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create token: %s", err)
+	}
+
+	gotUserID, gotLinkID, err := ValidateMagicLinkToken(token, "test")
+	if err != nil {
+		t.Fatalf("failed to validate token: %s", err)
+	}
+
+	if gotUserID != userID || gotLinkID != linkID {
+		t.Errorf("expected %v/%v, got %v/%v", userID, linkID, gotUserID, gotLinkID)
+	}
+
+	if _, _, err := ValidateMagicLinkToken(token, "other"); err == nil {
+		t.Errorf("expected token signed with another secret to be rejected")
+	}
+
+	if _, err := ValidateJWT(token, "test"); err == nil {
+		t.Errorf("expected magic link token to be rejected as an access token")
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// Example from RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !VerifyPKCE(verifier, challenge) {
+		t.Errorf("expected RFC 7636 example to verify")
+	}
+
+	sum := sha256.Sum256([]byte("short"))
+	if VerifyPKCE("short", base64.RawURLEncoding.EncodeToString(sum[:])) {
+		t.Errorf("expected verifier shorter than 43 characters to be rejected")
+	}
+
+	if VerifyPKCE(verifier+"x", challenge) {
+		t.Errorf("expected mismatched verifier to be rejected")
+	}
+}
+
+func TestOAuthAccessTokenScopes(t *testing.T) {
+	userID := uuid.New()
+	token, tokenID, err := MakeOAuthAccessToken(userID, "client-1", []string{"chirps:write"}, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create token: %s", err)
+	}
+
+	claims, err := ValidateAccessToken(token, "test")
+	if err != nil {
+		t.Fatalf("failed to validate token: %s", err)
+	}
+
+	if claims.UserID != userID || claims.TokenID != tokenID || claims.ClientID != "client-1" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+
+	if !claims.HasScope("chirps:write") || claims.HasScope("chirps:delete") {
+		t.Errorf("unexpected scopes: %v", claims.Scopes)
+	}
+
+	if _, err := ValidateJWT(token, "test"); err == nil {
+		t.Errorf("expected OAuth token to be rejected by ValidateJWT")
+	}
+
+	firstParty, err := MakeJWT(userID, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create JWT: %s", err)
+	}
+
+	claims, err = ValidateAccessToken(firstParty, "test")
+	if err != nil {
+		t.Fatalf("failed to validate JWT: %s", err)
+	}
+
+	if claims.IsThirdParty() || !claims.HasScope("chirps:delete") {
+		t.Errorf("expected first-party token to have every scope")
+	}
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrPasswordMismatch = errors.New("password does not match hash")
+
+// PasswordHasher produces and checks encoded password hashes. The encoding
+// names the algorithm and its parameters, so hashes made with older settings
+// keep verifying after the configuration changes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded, password string) error
+	// NeedsRehash reports whether encoded was made with a different
+	// algorithm or different parameters than this hasher would use now.
+	NeedsRehash(encoded string) bool
+}
+
+var (
+	hasherMu      sync.RWMutex
+	currentHasher PasswordHasher = NewArgon2idHasher(DefaultArgon2idParams)
+)
+
+// SetPasswordHasher changes the hasher used for new passwords. Existing
+// hashes are still verified by whichever algorithm produced them.
+func SetPasswordHasher(h PasswordHasher) {
+	hasherMu.Lock()
+	defer hasherMu.Unlock()
+	currentHasher = h
+}
+
+func passwordHasher() PasswordHasher {
+	hasherMu.RLock()
+	defer hasherMu.RUnlock()
+	return currentHasher
+}
+
+func HashPassword(password string) (string, error) {
+	hashedPassword, err := passwordHasher().Hash(password)
+	if err != nil {
+		return "", err
+	}
+
+	return hashedPassword, nil
+}
+
+func CheckPasswordHash(hash, password string) error {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		return Argon2idHasher{}.Verify(hash, password)
+	case isBcryptHash(hash):
+		return BcryptHasher{}.Verify(hash, password)
+	default:
+		return errors.New("unrecognized password hash format")
+	}
+}
+
+// PasswordNeedsRehash reports whether a stored hash should be replaced with
+// one from the current hasher the next time the plaintext is available.
+func PasswordNeedsRehash(hash string) bool {
+	return passwordHasher().NeedsRehash(hash)
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashedPassword), nil
+}
+
+func (h BcryptHasher) Verify(encoded, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	if !isBcryptHash(encoded) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+
+	return cost != h.Cost
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") ||
+		strings.HasPrefix(hash, "$2b$") ||
+		strings.HasPrefix(hash, "$2y$")
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// Limits on the Argon2id parameters read from a stored hash, so a corrupt or
+// planted hash can't make verifying it panic, exhaust memory, or match any
+// password.
+const (
+	// maxArgon2idMemory is in KiB.
+	maxArgon2idMemory     = 4 * 1024 * 1024
+	maxArgon2idIterations = 16
+	minArgon2idKeyLength  = 16
+)
+
+type Argon2idParams struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follows the second recommended option in RFC 9106
+// for systems that can't spare 2 GiB per hash.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+type Argon2idHasher struct {
+	Params Argon2idParams
+}
+
+func NewArgon2idHasher(params Argon2idParams) Argon2idHasher {
+	return Argon2idHasher{Params: params}
+}
+
+// Hash returns the hash in the PHC string format used by the reference
+// implementation, e.g. $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", errors.New("Error generating salt")
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		h.Params.Memory,
+		h.Params.Iterations,
+		h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Verify(encoded, password string) error {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return ErrPasswordMismatch
+	}
+
+	return nil
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params != h.Params
+}
+
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2idParams{}, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	params := Argon2idParams{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	if params.Iterations < 1 || params.Iterations > maxArgon2idIterations {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("argon2id iterations %d out of range", params.Iterations)
+	}
+	if params.Parallelism < 1 {
+		return Argon2idParams{}, nil, nil, errors.New("argon2id parallelism must be at least 1")
+	}
+	if params.Memory < 8*uint32(params.Parallelism) || params.Memory > maxArgon2idMemory {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("argon2id memory %d KiB out of range", params.Memory)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	if len(key) < minArgon2idKeyLength {
+		return Argon2idParams{}, nil, nil, fmt.Errorf("argon2id key of %d bytes is too short", len(key))
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
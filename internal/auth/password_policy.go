@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every reason a password was rejected so clients
+// can show them all at once.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return "password rejected: " + strings.Join(messages, "; ")
+}
+
+type PasswordPolicy struct {
+	MinLength      int
+	MinEntropyBits float64
+	// Breached is optional; when nil no breach check is made.
+	Breached *BreachedPasswords
+}
+
+// Validate checks password against the policy. userInputs are values the
+// user has told us, such as their email address, which make a password
+// easier to guess if it contains them. A *PasswordPolicyError is returned
+// for rejected passwords, any other error means the check itself failed.
+func (p PasswordPolicy) Validate(password string, userInputs ...string) error {
+	violations := []PasswordViolation{}
+
+	length := len([]rune(password))
+	if length < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    "too_short",
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	entropy := EstimatePasswordEntropy(password, userInputs...)
+	if length > 0 && entropy < p.MinEntropyBits {
+		violations = append(violations, PasswordViolation{
+			Code:    "too_weak",
+			Message: "Password is too easy to guess; avoid common words, repeated characters, sequences and your email address",
+		})
+	}
+
+	if p.Breached != nil && password != "" {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, PasswordViolation{
+				Code:    "breached",
+				Message: "Password has appeared in a known data breach",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+// EstimatePasswordEntropy gives a rough guessability estimate in bits, in the
+// spirit of zxcvbn: the password is split into the cheapest patterns an
+// attacker would try (user inputs, common passwords, repeats and sequences),
+// and whatever is left is charged at the brute-force cost of its character
+// set.
+func EstimatePasswordEntropy(password string, userInputs ...string) float64 {
+	runes := []rune(password)
+	perChar := math.Log2(float64(characterPoolSize(runes)))
+
+	// Pattern matching is quadratic, so very long passwords only have their
+	// start analysed. The rest is charged at full brute-force cost.
+	bits := 0.0
+	if len(runes) > maxAnalysedRunes {
+		bits += perChar * float64(len(runes)-maxAnalysedRunes)
+		runes = runes[:maxAnalysedRunes]
+	}
+
+	lower := []rune(strings.ToLower(string(runes)))
+	inputs := userInputTokens(userInputs)
+
+	for i := 0; i < len(runes); {
+		if n := longestMatch(lower, i, inputs); n >= 3 {
+			bits += math.Log2(float64(len(inputs)) + 1)
+			i += n
+			continue
+		}
+
+		if n, rank := commonPasswordMatch(lower, i); n > 0 {
+			bits += math.Log2(float64(rank) + 1)
+			if hasUpper(runes[i : i+n]) {
+				bits++
+			}
+			i += n
+			continue
+		}
+
+		if n := repeatRun(lower, i); n >= 3 {
+			bits += perChar + math.Log2(float64(n))
+			i += n
+			continue
+		}
+
+		if n := longestMatch(lower, i, sequences); n >= 3 {
+			bits += perChar + math.Log2(float64(n))
+			i += n
+			continue
+		}
+
+		bits += perChar
+		i++
+	}
+
+	return bits
+}
+
+const maxAnalysedRunes = 128
+
+func characterPoolSize(runes []rune) int {
+	var lower, upper, digit, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if other {
+		size += 33
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+func hasUpper(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func userInputTokens(userInputs []string) []string {
+	tokens := []string{}
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		fields := strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, token := range append(fields, input) {
+			if len([]rune(token)) >= 3 {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+// longestMatch returns the length of the longest substring starting at i
+// that appears in any of the candidates.
+func longestMatch(lower []rune, i int, candidates []string) int {
+	best := 0
+	for end := len(lower); end > i+best; end-- {
+		sub := string(lower[i:end])
+		for _, candidate := range candidates {
+			if strings.Contains(candidate, sub) {
+				best = end - i
+				break
+			}
+		}
+	}
+	return best
+}
+
+func commonPasswordMatch(lower []rune, i int) (int, int) {
+	for end := len(lower); end-i >= 4; end-- {
+		if rank, ok := commonPasswordRanks[string(lower[i:end])]; ok {
+			return end - i, rank
+		}
+	}
+	return 0, 0
+}
+
+func repeatRun(lower []rune, i int) int {
+	n := 1
+	for i+n < len(lower) && lower[i+n] == lower[i] {
+		n++
+	}
+	return n
+}
+
+var sequences = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"zyxwvutsrqponmlkjihgfedcba",
+	"01234567890",
+	"09876543210",
+	"qwertyuiop",
+	"poiuytrewq",
+	"asdfghjkl",
+	"lkjhgfdsa",
+	"zxcvbnm",
+	"mnbvcxz",
+}
+
+var commonPasswordRanks = func() map[string]int {
+	common := []string{
+		"password", "123456", "12345678", "qwerty", "abc123", "111111",
+		"letmein", "monkey", "dragon", "baseball", "iloveyou", "trustno1",
+		"sunshine", "master", "welcome", "shadow", "ashley", "football",
+		"jesus", "michael", "ninja", "mustang", "princess", "superman",
+		"starwars", "whatever", "freedom", "charlie", "secret", "hello",
+		"admin", "login", "passw0rd", "p@ssword", "p@ssw0rd", "changeme",
+		"chirpy", "chirp", "summer", "winter", "spring", "autumn",
+		"soccer", "hockey", "batman", "pokemon", "computer", "internet",
+		"love", "qazwsx", "zaq12wsx", "access", "flower", "hunter",
+	}
+
+	ranks := make(map[string]int, len(common))
+	for i, word := range common {
+		ranks[word] = i + 1
+	}
+	return ranks
+}()
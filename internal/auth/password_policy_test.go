@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyRejectsWeakPasswords(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MinEntropyBits: 28}
+
+	cases := map[string]string{
+		"":                  "too_short",
+		"Tr0ub4":            "too_short",
+		"password1":         "too_weak",
+		"aaaaaaaaaaaa":      "too_weak",
+		"qwertyuiop12":      "too_weak",
+		"alice@example.com": "too_weak",
+	}
+
+	for password, code := range cases {
+		err := policy.Validate(password, "alice@example.com")
+		var policyErr *PasswordPolicyError
+		if !errors.As(err, &policyErr) {
+			t.Errorf("%q: expected a PasswordPolicyError, got %v", password, err)
+			continue
+		}
+
+		found := false
+		for _, v := range policyErr.Violations {
+			if v.Code == code {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%q: expected violation %s, got %+v", password, code, policyErr.Violations)
+		}
+	}
+}
+
+func TestPasswordPolicyAcceptsStrongPassword(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MinEntropyBits: 28}
+
+	if err := policy.Validate("Tr0ub4dor&3-horse", "alice@example.com"); err != nil {
+		t.Errorf("expected password to be accepted, got %v", err)
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	breached := []string{"password", "hunter2", "correct horse battery staple"}
+	lines := []string{}
+	for _, password := range append(breached, "filler-one", "filler-two", "filler-three") {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":42")
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write dataset: %s", err)
+	}
+
+	list, err := OpenBreachedPasswords(path)
+	if err != nil {
+		t.Fatalf("failed to open dataset: %s", err)
+	}
+
+	for _, password := range append(breached, "filler-one", "filler-three") {
+		found, err := list.Contains(password)
+		if err != nil {
+			t.Fatalf("lookup failed: %s", err)
+		}
+		if !found {
+			t.Errorf("expected %q to be found", password)
+		}
+	}
+
+	found, err := list.Contains("not in the list")
+	if err != nil {
+		t.Fatalf("lookup failed: %s", err)
+	}
+	if found {
+		t.Errorf("expected password not to be found")
+	}
+
+	policy := PasswordPolicy{Breached: list}
+	var policyErr *PasswordPolicyError
+	if err := policy.Validate("hunter2"); !errors.As(err, &policyErr) || policyErr.Violations[0].Code != "breached" {
+		t.Errorf("expected breached violation, got %v", err)
+	}
+}
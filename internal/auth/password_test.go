@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHashAndCheck(t *testing.T) {
+	SetPasswordHasher(NewArgon2idHasher(testArgon2idParams))
+	defer SetPasswordHasher(NewArgon2idHasher(DefaultArgon2idParams))
+
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("failed to hash password: %s", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected hash encoding: %s", hash)
+	}
+
+	if err := CheckPasswordHash(hash, "correct horse battery staple"); err != nil {
+		t.Errorf("expected password to match, got %v", err)
+	}
+
+	err = CheckPasswordHash(hash, "wrong")
+	if !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("expected ErrPasswordMismatch, got %v", err)
+	}
+
+	if PasswordNeedsRehash(hash) {
+		t.Errorf("expected hash with current parameters not to need a rehash")
+	}
+}
+
+func TestArgon2idDoesNotTruncateLongPasswords(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2idParams)
+	prefix := strings.Repeat("a", 72)
+
+	hash, err := hasher.Hash(prefix + "b")
+	if err != nil {
+		t.Fatalf("failed to hash password: %s", err)
+	}
+
+	if err := hasher.Verify(hash, prefix+"c"); err == nil {
+		t.Errorf("expected passwords differing after 72 bytes not to match")
+	}
+}
+
+func TestLegacyBcryptHashStillVerifies(t *testing.T) {
+	SetPasswordHasher(NewArgon2idHasher(testArgon2idParams))
+	defer SetPasswordHasher(NewArgon2idHasher(DefaultArgon2idParams))
+
+	legacy, err := BcryptHasher{Cost: 4}.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("failed to hash password: %s", err)
+	}
+
+	if err := CheckPasswordHash(legacy, "hunter2"); err != nil {
+		t.Errorf("expected bcrypt hash to verify, got %v", err)
+	}
+
+	if !PasswordNeedsRehash(legacy) {
+		t.Errorf("expected bcrypt hash to need a rehash")
+	}
+
+	stronger := NewArgon2idHasher(Argon2idParams{
+		Memory:      2048,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	})
+	current, _ := HashPassword("hunter2")
+	if !stronger.NeedsRehash(current) {
+		t.Errorf("expected hash with old parameters to need a rehash")
+	}
+}
+
+func TestArgon2idRejectsOutOfRangeParameters(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2idParams)
+	hash, err := hasher.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("failed to hash password: %s", err)
+	}
+	parts := strings.Split(hash, "$")
+
+	tests := map[string]struct {
+		params   string
+		emptyKey bool
+	}{
+		"no iterations":       {params: "m=1024,t=0,p=1"},
+		"too many iterations": {params: "m=1024,t=17,p=1"},
+		"no parallelism":      {params: "m=1024,t=1,p=0"},
+		"too little memory":   {params: "m=16,t=1,p=4"},
+		"too much memory":     {params: "m=4194305,t=1,p=1"},
+		"empty key":           {params: "m=1024,t=1,p=1", emptyKey: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			encoded := append([]string{}, parts...)
+			encoded[3] = tt.params
+			if tt.emptyKey {
+				encoded[5] = ""
+			}
+
+			err := hasher.Verify(strings.Join(encoded, "$"), "hunter2")
+			if err == nil || errors.Is(err, ErrPasswordMismatch) {
+				t.Errorf("expected a malformed hash error, got %v", err)
+			}
+		})
+	}
+}
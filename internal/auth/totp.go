@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpDigits = 6
+	totpPeriod = 30
+	// totpSkew is the number of periods either side of the current one
+	// that are still accepted, to allow for clock drift on the device.
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTOTPSecret() (string, error) {
+	key := make([]byte, 20)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", errors.New("Error generating TOTP secret")
+	}
+
+	return totpEncoding.EncodeToString(key), nil
+}
+
+func TOTPURI(issuer, accountName, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer + ":" + accountName)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+func ValidateTOTP(secret, code string, now time.Time) bool {
+	_, ok := MatchTOTP(secret, code, now, -1)
+	return ok
+}
+
+// MatchTOTP checks code like ValidateTOTP, but only against time steps after
+// lastStep, and returns the step it matched. Storing that step and passing it
+// back in as lastStep keeps a code from being used twice.
+func MatchTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	counter := now.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := counter + int64(i)
+		if step <= lastStep {
+			continue
+		}
+		expected := generateTOTP(key, uint64(step))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// generateTOTP implements the HOTP truncation from RFC 4226 for the given
+// counter, which RFC 6238 derives from the current time.
+func generateTOTP(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+func MakeRecoveryCodes(count int) ([]string, error) {
+	codes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		key := make([]byte, 5)
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, errors.New("Error generating recovery codes")
+		}
+
+		encoded := hex.EncodeToString(key)
+		codes = append(codes, encoded[:5]+"-"+encoded[5:])
+	}
+
+	return codes, nil
+}
+
+// HashRecoveryCode returns the value stored for a recovery code. The codes
+// are random enough that a fast hash is sufficient, and it lets us look a
+// code up directly instead of comparing against every stored hash.
+func HashRecoveryCode(code string) string {
+	return HashToken(strings.ToLower(strings.TrimSpace(code)))
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGenerateTOTPMatchesRFC6238(t *testing.T) {
+	// Test vectors from RFC 6238 appendix B, truncated to six digits.
+	key := []byte("12345678901234567890")
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, want := range cases {
+		got := generateTOTP(key, uint64(unix/totpPeriod))
+		if got != want {
+			t.Errorf("at %d expected %s, got %s", unix, want, got)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+
+	if !ValidateTOTP(secret, "081804", now) {
+		t.Errorf("expected current code to be accepted")
+	}
+
+	if !ValidateTOTP(secret, "081804", now.Add(totpPeriod*time.Second)) {
+		t.Errorf("expected code from the previous period to be accepted")
+	}
+
+	if ValidateTOTP(secret, "081804", now.Add(5*totpPeriod*time.Second)) {
+		t.Errorf("expected stale code to be rejected")
+	}
+
+	if ValidateTOTP(secret, "000000", now) {
+		t.Errorf("expected wrong code to be rejected")
+	}
+}
+
+func TestMatchTOTPRejectsUsedSteps(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+
+	step, ok := MatchTOTP(secret, "081804", now, -1)
+	if !ok || step != 1111111109/totpPeriod {
+		t.Fatalf("expected current code to match its step, got %d, %v", step, ok)
+	}
+
+	if _, ok := MatchTOTP(secret, "081804", now, step); ok {
+		t.Errorf("expected a code from an already used step to be rejected")
+	}
+
+	if _, ok := MatchTOTP(secret, "081804", now.Add(totpPeriod*time.Second), step+1); ok {
+		t.Errorf("expected a code older than the last used step to be rejected")
+	}
+}
+
+func TestTOTPChallengeIsNotAnAccessToken(t *testing.T) {
+	userID := uuid.New()
+	challengeID := uuid.New()
+	challenge, err := MakeTOTPChallenge(userID, challengeID, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create challenge: %s", err)
+	}
+
+	if _, err := ValidateJWT(challenge, "test"); err == nil {
+		t.Errorf("expected challenge token to be rejected as an access token")
+	}
+
+	gotUserID, gotChallengeID, err := ValidateTOTPChallenge(challenge, "test")
+	if err != nil {
+		t.Fatalf("failed to validate challenge: %s", err)
+	}
+
+	if gotUserID != userID || gotChallengeID != challengeID {
+		t.Errorf("expected %v/%v, got %v/%v", userID, challengeID, gotUserID, gotChallengeID)
+	}
+}
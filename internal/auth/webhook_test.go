@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"user.upgraded"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := SignWebhook("new", now, body)
+
+	tests := []struct {
+		name      string
+		secrets   []string
+		timestamp string
+		signature string
+		body      []byte
+		wantErr   error
+	}{
+		{"valid", []string{"new"}, timestamp, signature, body, nil},
+		{"valid during rotation", []string{"old", "new"}, timestamp, signature, body, nil},
+		{"one of several signatures", []string{"new"}, timestamp, "v1=00ff," + signature, body, nil},
+		{"wrong secret", []string{"old"}, timestamp, signature, body, ErrWebhookSignature},
+		{"tampered body", []string{"new"}, timestamp, signature, []byte(`{"event":"user.downgraded"}`), ErrWebhookSignature},
+		{"timestamp not signed", []string{"new"}, strconv.FormatInt(now.Unix()+1, 10), signature, body, ErrWebhookSignature},
+		{"stale timestamp", []string{"new"}, strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10), SignWebhook("new", now.Add(-10*time.Minute), body), body, ErrWebhookTimestamp},
+		{"missing timestamp", []string{"new"}, "", signature, body, ErrWebhookTimestamp},
+		{"unknown version", []string{"new"}, timestamp, "v0" + signature[2:], body, ErrWebhookSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.secrets, tt.timestamp, tt.signature, tt.body, now, 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
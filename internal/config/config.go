@@ -0,0 +1,503 @@
+// Package config loads Chirpy's settings. Each setting can come from, in
+// increasing order of precedence: its default, the selected profile, a JSON
+// config file, the environment (including .env) and a command-line flag.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ProfileDev        = "dev"
+	ProfileTest       = "test"
+	ProfileProduction = "production"
+)
+
+// minProductionSecretLength is the shortest SECRET the production profile
+// accepts; tokens are signed with HS256, which wants at least 256 bits.
+const minProductionSecretLength = 32
+
+// Limits on the Argon2id costs: below them hashes are too cheap to be worth
+// having, above them every login takes seconds or gigabytes.
+const (
+	minArgon2MemoryKiB  = 8 * 1024
+	maxArgon2MemoryKiB  = 4 * 1024 * 1024
+	maxArgon2Iterations = 16
+)
+
+// Config is the typed, validated result of loading. Zero numeric values for
+// the Argon2 settings mean the hasher's own defaults.
+type Config struct {
+	Profile string
+
+	Port         string
+	FilepathRoot string
+	BaseURL      string
+	Platform     string
+	DBURL        string
+	AutoMigrate  bool
+	Secret       string
+
+	PolkaKey            string
+	PolkaWebhookSecrets []string
+
+	RequireEmailVerification bool
+	EntitlementsPath         string
+	MediaDir                 string
+
+	Mailer       string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	MailLogPath  string
+
+	PasswordHasher         string
+	Argon2MemoryKiB        uint32
+	Argon2Iterations       uint32
+	Argon2Parallelism      uint8
+	BcryptCost             int
+	PasswordMinLength      int
+	PasswordMinEntropyBits float64
+	BreachedPasswordsPath  string
+
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+
+	LogFormat        string
+	LogLevel         string
+	MetricsAddr      string
+	OTLPEndpoint     string
+	ServiceName      string
+	TraceSampleRatio float64
+	ShutdownDelay    time.Duration
+	ShutdownTimeout  time.Duration
+
+	values map[string]value
+}
+
+// value is a setting's raw value and where it came from.
+type value struct {
+	raw    string
+	source string
+}
+
+type setting struct {
+	key    string
+	usage  string
+	secret bool
+	apply  func(c *Config, raw string) error
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.key), "_", "-")
+}
+
+func field[T any](key, usage string, ptr func(*Config) *T, parse func(string) (T, error)) setting {
+	return setting{
+		key:   key,
+		usage: usage,
+		apply: func(c *Config, raw string) error {
+			v, err := parse(raw)
+			if err != nil {
+				return err
+			}
+			*ptr(c) = v
+			return nil
+		},
+	}
+}
+
+func secret(s setting) setting {
+	s.secret = true
+	return s
+}
+
+func parseString(raw string) (string, error) {
+	return raw, nil
+}
+
+func parseList(raw string) ([]string, error) {
+	items := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func parseUint32(raw string) (uint32, error) {
+	v, err := strconv.ParseUint(raw, 10, 32)
+	return uint32(v), err
+}
+
+func parseUint8(raw string) (uint8, error) {
+	v, err := strconv.ParseUint(raw, 10, 8)
+	return uint8(v), err
+}
+
+func parseFloat(raw string) (float64, error) {
+	return strconv.ParseFloat(raw, 64)
+}
+
+// settings lists everything that can be configured, in the order it's
+// printed.
+var settings = []setting{
+	field("PORT", "port to listen on", func(c *Config) *string { return &c.Port }, parseString),
+	field("FILEPATH_ROOT", "directory served under /app/", func(c *Config) *string { return &c.FilepathRoot }, parseString),
+	field("BASE_URL", "public URL Chirpy is reached at, used in emails and redirects", func(c *Config) *string { return &c.BaseURL }, parseString),
+	field("PLATFORM", `"dev" enables development-only behaviour such as /admin/reset`, func(c *Config) *string { return &c.Platform }, parseString),
+	secret(field("DB_URL", "Postgres connection string", func(c *Config) *string { return &c.DBURL }, parseString)),
+	field("AUTO_MIGRATE", "apply pending migrations at startup", func(c *Config) *bool { return &c.AutoMigrate }, strconv.ParseBool),
+	secret(field("SECRET", "key used to sign access tokens", func(c *Config) *string { return &c.Secret }, parseString)),
+
+	secret(field("POLKA_KEY", "API key Polka webhooks may authenticate with", func(c *Config) *string { return &c.PolkaKey }, parseString)),
+	secret(field("POLKA_WEBHOOK_SECRETS", "comma-separated secrets Polka webhooks are signed with", func(c *Config) *[]string { return &c.PolkaWebhookSecrets }, parseList)),
+
+	field("REQUIRE_EMAIL_VERIFICATION", "refuse logins until the email address is verified", func(c *Config) *bool { return &c.RequireEmailVerification }, strconv.ParseBool),
+	field("ENTITLEMENTS_PATH", "JSON file describing plan entitlements", func(c *Config) *string { return &c.EntitlementsPath }, parseString),
+	field("MEDIA_DIR", "directory chirp media is stored in", func(c *Config) *string { return &c.MediaDir }, parseString),
+
+	field("MAILER", `"smtp" to send email, anything else logs it`, func(c *Config) *string { return &c.Mailer }, parseString),
+	field("SMTP_HOST", "SMTP server host", func(c *Config) *string { return &c.SMTPHost }, parseString),
+	field("SMTP_PORT", "SMTP server port", func(c *Config) *string { return &c.SMTPPort }, parseString),
+	field("SMTP_USERNAME", "SMTP username", func(c *Config) *string { return &c.SMTPUsername }, parseString),
+	secret(field("SMTP_PASSWORD", "SMTP password", func(c *Config) *string { return &c.SMTPPassword }, parseString)),
+	field("SMTP_FROM", "address email is sent from", func(c *Config) *string { return &c.SMTPFrom }, parseString),
+	field("MAIL_LOG_PATH", "file logged email is appended to instead of stdout", func(c *Config) *string { return &c.MailLogPath }, parseString),
+
+	field("PASSWORD_HASHER", `"argon2id" or "bcrypt"`, func(c *Config) *string { return &c.PasswordHasher }, parseString),
+	field("ARGON2_MEMORY_KIB", "Argon2id memory cost", func(c *Config) *uint32 { return &c.Argon2MemoryKiB }, parseUint32),
+	field("ARGON2_ITERATIONS", "Argon2id time cost", func(c *Config) *uint32 { return &c.Argon2Iterations }, parseUint32),
+	field("ARGON2_PARALLELISM", "Argon2id parallelism", func(c *Config) *uint8 { return &c.Argon2Parallelism }, parseUint8),
+	field("BCRYPT_COST", "bcrypt cost", func(c *Config) *int { return &c.BcryptCost }, strconv.Atoi),
+	field("PASSWORD_MIN_LENGTH", "shortest password accepted", func(c *Config) *int { return &c.PasswordMinLength }, strconv.Atoi),
+	field("PASSWORD_MIN_ENTROPY_BITS", "least estimated password entropy accepted", func(c *Config) *float64 { return &c.PasswordMinEntropyBits }, parseFloat),
+	field("BREACHED_PASSWORDS_PATH", "file of breached password hashes to reject", func(c *Config) *string { return &c.BreachedPasswordsPath }, parseString),
+
+	field("OIDC_ISSUER", "OpenID Connect issuer for single sign-on", func(c *Config) *string { return &c.OIDCIssuer }, parseString),
+	field("OIDC_CLIENT_ID", "OpenID Connect client ID", func(c *Config) *string { return &c.OIDCClientID }, parseString),
+	secret(field("OIDC_CLIENT_SECRET", "OpenID Connect client secret", func(c *Config) *string { return &c.OIDCClientSecret }, parseString)),
+
+	field("LOG_FORMAT", `"text" or "json"`, func(c *Config) *string { return &c.LogFormat }, parseString),
+	field("LOG_LEVEL", "least severe level logged", func(c *Config) *string { return &c.LogLevel }, parseString),
+	field("METRICS_ADDR", "private address to serve /metrics on without auth, such as 127.0.0.1:9090; unset serves it to admins on PORT", func(c *Config) *string { return &c.MetricsAddr }, parseString),
+	field("OTEL_EXPORTER_OTLP_ENDPOINT", "OTLP/HTTP collector traces are exported to", func(c *Config) *string { return &c.OTLPEndpoint }, parseString),
+	field("OTEL_SERVICE_NAME", "service name traces are reported under", func(c *Config) *string { return &c.ServiceName }, parseString),
+	field("TRACE_SAMPLE_RATIO", "fraction of new traces recorded", func(c *Config) *float64 { return &c.TraceSampleRatio }, parseFloat),
+	field("SHUTDOWN_DELAY", "how long readyz fails before connections are drained on shutdown", func(c *Config) *time.Duration { return &c.ShutdownDelay }, time.ParseDuration),
+	field("SHUTDOWN_TIMEOUT", "how long to wait for requests to drain on shutdown", func(c *Config) *time.Duration { return &c.ShutdownTimeout }, time.ParseDuration),
+}
+
+var defaults = map[string]string{
+	"PORT":                      "8080",
+	"FILEPATH_ROOT":             ".",
+	"MEDIA_DIR":                 "media",
+	"PASSWORD_HASHER":           "argon2id",
+	"BCRYPT_COST":               "10",
+	"PASSWORD_MIN_LENGTH":       "8",
+	"PASSWORD_MIN_ENTROPY_BITS": "28",
+	"SMTP_PORT":                 "587",
+	"LOG_FORMAT":                "text",
+	"LOG_LEVEL":                 "info",
+	"OTEL_SERVICE_NAME":         "chirpy",
+	"TRACE_SAMPLE_RATIO":        "1",
+	"SHUTDOWN_DELAY":            "5s",
+	"SHUTDOWN_TIMEOUT":          "30s",
+}
+
+// profiles override the defaults for each environment. Production is also
+// validated more strictly.
+var profiles = map[string]map[string]string{
+	ProfileDev: {
+		"PLATFORM":       "dev",
+		"AUTO_MIGRATE":   "true",
+		"LOG_LEVEL":      "debug",
+		"SHUTDOWN_DELAY": "0s",
+	},
+	ProfileTest: {
+		"PLATFORM":                   "dev",
+		"AUTO_MIGRATE":               "true",
+		"PASSWORD_HASHER":            "bcrypt",
+		"BCRYPT_COST":                "4",
+		"LOG_LEVEL":                  "warn",
+		"REQUIRE_EMAIL_VERIFICATION": "false",
+		"SHUTDOWN_DELAY":             "0s",
+	},
+	ProfileProduction: {
+		"LOG_FORMAT":                 "json",
+		"REQUIRE_EMAIL_VERIFICATION": "true",
+		"TRACE_SAMPLE_RATIO":         "0.1",
+	},
+}
+
+// Load reads the configuration. args are the command-line arguments after
+// the program name; those left after the flags are returned. lookupEnv is
+// normally os.LookupEnv. The profile comes from -profile or CHIRPY_PROFILE
+// and the config file from -config or CHIRPY_CONFIG.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, []string, error) {
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	profile := fs.String("profile", "", "configuration profile: dev, test or production")
+	configPath := fs.String("config", "", "JSON config file")
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		flagValues[s.key] = fs.String(s.flagName(), "", s.usage)
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	setFlags := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	if *profile == "" {
+		*profile, _ = lookupEnv("CHIRPY_PROFILE")
+	}
+	if *configPath == "" {
+		*configPath, _ = lookupEnv("CHIRPY_CONFIG")
+	}
+
+	values := map[string]value{}
+	for key, raw := range defaults {
+		values[key] = value{raw: raw, source: "default"}
+	}
+
+	if *profile != "" {
+		overrides, ok := profiles[*profile]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown profile %q", *profile)
+		}
+		for key, raw := range overrides {
+			values[key] = value{raw: raw, source: "profile " + *profile}
+		}
+	}
+
+	if *configPath != "" {
+		fileValues, err := readFile(*configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for key, raw := range fileValues {
+			values[key] = value{raw: raw, source: *configPath}
+		}
+	}
+
+	for _, s := range settings {
+		if raw, ok := lookupEnv(s.key); ok && raw != "" {
+			values[s.key] = value{raw: raw, source: "environment"}
+		}
+		if setFlags[s.flagName()] {
+			values[s.key] = value{raw: *flagValues[s.key], source: "flag -" + s.flagName()}
+		}
+	}
+
+	cfg := &Config{Profile: *profile, values: values}
+	errs := []error{}
+	for _, s := range settings {
+		v, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		err := s.apply(cfg, v.raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s (from %s): %w", s.key, v.source, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:" + cfg.Port
+		cfg.values["BASE_URL"] = value{raw: cfg.BaseURL, source: "default"}
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// readFile reads a config file: a JSON object keyed by setting name, such
+// as {"PORT": 8080, "LOG_FORMAT": "json"}. Lists may be given as arrays.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse config file %s: %w", path, err)
+	}
+
+	known := map[string]bool{}
+	for _, s := range settings {
+		known[s.key] = true
+	}
+
+	values := map[string]string{}
+	for key, v := range raw {
+		if !known[key] {
+			return nil, fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+
+		switch v := v.(type) {
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+
+	return values, nil
+}
+
+// Validate checks that required settings are present and that values make
+// sense together, reporting every problem at once.
+func (c *Config) Validate() error {
+	errs := []error{}
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.DBURL == "" {
+		fail("DB_URL is required")
+	}
+	if c.Secret == "" {
+		fail("SECRET is required")
+	}
+
+	port, err := strconv.Atoi(c.Port)
+	if err != nil || port < 1 || port > 65535 {
+		fail("PORT must be a port number, got %q", c.Port)
+	}
+
+	baseURL, err := url.Parse(c.BaseURL)
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		fail("BASE_URL must be an absolute URL, got %q", c.BaseURL)
+	}
+
+	if c.Mailer == "smtp" && (c.SMTPHost == "" || c.SMTPFrom == "") {
+		fail("MAILER=smtp needs SMTP_HOST and SMTP_FROM")
+	}
+
+	if c.PasswordHasher != "argon2id" && c.PasswordHasher != "bcrypt" {
+		fail("unknown PASSWORD_HASHER %q", c.PasswordHasher)
+	}
+
+	// bcrypt silently swaps a cost below its minimum for its default, which
+	// would make every login look like it needs a rehash, and fails every
+	// hash above its maximum.
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		fail("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if c.Argon2MemoryKiB != 0 && (c.Argon2MemoryKiB < minArgon2MemoryKiB || c.Argon2MemoryKiB > maxArgon2MemoryKiB) {
+		fail("ARGON2_MEMORY_KIB must be between %d and %d", minArgon2MemoryKiB, maxArgon2MemoryKiB)
+	}
+	if c.Argon2Iterations > maxArgon2Iterations {
+		fail("ARGON2_ITERATIONS must be at most %d", maxArgon2Iterations)
+	}
+
+	if c.OIDCIssuer != "" && c.OIDCClientID == "" {
+		fail("OIDC_ISSUER needs OIDC_CLIENT_ID")
+	}
+
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		fail("unknown LOG_FORMAT %q", c.LogFormat)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		fail("invalid LOG_LEVEL %q", c.LogLevel)
+	}
+
+	if c.MetricsAddr != "" {
+		_, metricsPort, err := net.SplitHostPort(c.MetricsAddr)
+		if err != nil || metricsPort == c.Port {
+			fail("METRICS_ADDR must be a host:port other than PORT, got %q", c.MetricsAddr)
+		}
+	}
+
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		fail("TRACE_SAMPLE_RATIO must be between 0 and 1")
+	}
+	if c.ShutdownDelay < 0 {
+		fail("SHUTDOWN_DELAY can't be negative")
+	}
+	if c.ShutdownTimeout <= 0 {
+		fail("SHUTDOWN_TIMEOUT must be positive")
+	}
+
+	if c.Profile == ProfileProduction {
+		if len(c.Secret) < minProductionSecretLength {
+			fail("SECRET must be at least %d characters in production", minProductionSecretLength)
+		}
+		if baseURL != nil && baseURL.Scheme != "https" {
+			fail("BASE_URL must use https in production")
+		}
+		if c.Platform == "dev" {
+			fail("PLATFORM can't be dev in production")
+		}
+		if c.PolkaKey == "" && len(c.PolkaWebhookSecrets) == 0 {
+			fail("POLKA_KEY or POLKA_WEBHOOK_SECRETS is required in production")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Print writes the effective configuration, one setting per line with
+// where its value came from. Secrets are redacted.
+func (c *Config) Print(w io.Writer) {
+	profile := c.Profile
+	if profile == "" {
+		profile = "(none)"
+	}
+	fmt.Fprintf(w, "# profile: %s\n", profile)
+
+	for _, s := range settings {
+		v, ok := c.values[s.key]
+		if !ok {
+			fmt.Fprintf(w, "%s=\n", s.key)
+			continue
+		}
+
+		raw := v.raw
+		if s.secret && raw != "" {
+			raw = "[redacted]"
+		}
+		fmt.Fprintf(w, "%s=%s  # %s\n", s.key, raw, v.source)
+	}
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envFrom(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "chirpy.json")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var requiredEnv = map[string]string{
+	"DB_URL": "postgres://localhost/chirpy",
+	"SECRET": "not-a-real-secret",
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, args, err := Load(nil, envFrom(requiredEnv))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Port != "8080" || cfg.FilepathRoot != "." || cfg.MediaDir != "media" {
+		t.Errorf("unexpected defaults: port %q, root %q, media %q", cfg.Port, cfg.FilepathRoot, cfg.MediaDir)
+	}
+	if cfg.BaseURL != "http://localhost:8080" {
+		t.Errorf("BaseURL = %q, want it derived from the port", cfg.BaseURL)
+	}
+	if cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("ShutdownTimeout = %v, want 30s", cfg.ShutdownTimeout)
+	}
+	if len(args) != 0 {
+		t.Errorf("args = %v, want none", args)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `{"PORT": 9000, "MEDIA_DIR": "/srv/media", "LOG_LEVEL": "error", "POLKA_WEBHOOK_SECRETS": ["a", "b"]}`)
+
+	env := map[string]string{
+		"CHIRPY_CONFIG": path,
+		"MEDIA_DIR":     "/env/media",
+		"LOG_LEVEL":     "warn",
+	}
+	for k, v := range requiredEnv {
+		env[k] = v
+	}
+
+	cfg, args, err := Load([]string{"-profile", "dev", "-log-level", "info", "promote-admin", "a@example.com"}, envFrom(env))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Platform != "dev" {
+		t.Errorf("Platform = %q, want the dev profile's value", cfg.Platform)
+	}
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %q, want the file's value", cfg.Port)
+	}
+	if cfg.MediaDir != "/env/media" {
+		t.Errorf("MediaDir = %q, want the environment to beat the file", cfg.MediaDir)
+	}
+	if cfg.LogLevel != "info" {
+		t.Errorf("LogLevel = %q, want the flag to beat everything", cfg.LogLevel)
+	}
+	if strings.Join(cfg.PolkaWebhookSecrets, ",") != "a,b" {
+		t.Errorf("PolkaWebhookSecrets = %v, want the file's list", cfg.PolkaWebhookSecrets)
+	}
+	if strings.Join(args, " ") != "promote-admin a@example.com" {
+		t.Errorf("args = %v, want the command after the flags", args)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	_, _, err := Load([]string{"-port", "http", "-bcrypt-cost", "ten"}, envFrom(nil))
+	if err == nil {
+		t.Fatal("Load succeeded with invalid settings")
+	}
+	if !strings.Contains(err.Error(), "BCRYPT_COST") {
+		t.Errorf("error %q doesn't mention the unparseable setting", err)
+	}
+
+	_, _, err = Load([]string{"-port", "http"}, envFrom(nil))
+	if err == nil {
+		t.Fatal("Load succeeded with invalid settings")
+	}
+	for _, want := range []string{"DB_URL is required", "SECRET is required", "PORT must be a port number"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadRejectsOutOfRangeHashCosts(t *testing.T) {
+	_, _, err := Load([]string{"-bcrypt-cost", "3", "-argon2-memory-kib", "1024", "-argon2-iterations", "100"}, envFrom(requiredEnv))
+	if err == nil {
+		t.Fatal("Load accepted out-of-range hashing costs")
+	}
+	for _, want := range []string{"BCRYPT_COST", "ARGON2_MEMORY_KIB", "ARGON2_ITERATIONS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+
+	_, _, err = Load([]string{"-bcrypt-cost", "32"}, envFrom(requiredEnv))
+	if err == nil {
+		t.Error("Load accepted a bcrypt cost above the maximum")
+	}
+}
+
+func TestLoadProductionIsStricter(t *testing.T) {
+	_, _, err := Load([]string{"-profile", "production"}, envFrom(requiredEnv))
+	if err == nil {
+		t.Fatal("production accepted a short secret and an http base URL")
+	}
+	for _, want := range []string{"SECRET must be at least", "BASE_URL must use https", "POLKA_KEY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+
+	cfg, _, err := Load([]string{"-profile", "production"}, envFrom(map[string]string{
+		"DB_URL":    "postgres://db/chirpy",
+		"SECRET":    strings.Repeat("s", 32),
+		"BASE_URL":  "https://chirpy.example.com/",
+		"POLKA_KEY": "polka",
+	}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.RequireEmailVerification || cfg.LogFormat != "json" {
+		t.Errorf("production profile defaults weren't applied")
+	}
+	if cfg.BaseURL != "https://chirpy.example.com" {
+		t.Errorf("BaseURL = %q, want the trailing slash trimmed", cfg.BaseURL)
+	}
+}
+
+func TestLoadRejectsUnknownInputs(t *testing.T) {
+	_, _, err := Load([]string{"-profile", "staging"}, envFrom(requiredEnv))
+	if err == nil {
+		t.Error("Load accepted an unknown profile")
+	}
+
+	path := writeConfigFile(t, `{"PROT": 8080}`)
+	_, _, err = Load([]string{"-config", path}, envFrom(requiredEnv))
+	if err == nil || !strings.Contains(err.Error(), "PROT") {
+		t.Errorf("Load error = %v, want it to name the unknown setting", err)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg, _, err := Load([]string{"-smtp-password", "hunter2"}, envFrom(requiredEnv))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	out := &strings.Builder{}
+	cfg.Print(out)
+
+	for _, secret := range []string{"hunter2", "not-a-real-secret", "postgres://"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("printed config contains %q:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{"SECRET=[redacted]  # environment", "SMTP_PASSWORD=[redacted]  # flag -smtp-password", "PORT=8080  # default", "POLKA_KEY=\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printed config is missing %q:\n%s", want, out)
+		}
+	}
+}
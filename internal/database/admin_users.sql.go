@@ -0,0 +1,217 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: admin_users.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const countActiveSessions = `-- name: CountActiveSessions :one
+SELECT COUNT(*)
+FROM refresh_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND expires_at > NOW()
+`
+
+func (q *Queries) CountActiveSessions(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countActiveSessions, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getStats = `-- name: GetStats :one
+SELECT
+	(SELECT COUNT(*) FROM users) AS users,
+	(SELECT COUNT(*) FROM users WHERE role = 'admin') AS admins,
+	(SELECT COUNT(*) FROM users WHERE is_chirpy_red) AS chirpy_red_users,
+	(SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL) AS suspended_users,
+	(SELECT COUNT(*) FROM chirps) AS chirps,
+	(SELECT COUNT(*) FROM refresh_tokens WHERE revoked_at IS NULL AND expires_at > NOW()) AS active_sessions,
+	(SELECT COUNT(*) FROM refresh_tokens WHERE expires_at < NOW()) AS expired_refresh_tokens,
+	(SELECT COUNT(*) FROM webhook_deliveries WHERE status IN ('pending', 'retrying')) AS pending_webhook_deliveries
+`
+
+type GetStatsRow struct {
+	Users                    int64
+	Admins                   int64
+	ChirpyRedUsers           int64
+	SuspendedUsers           int64
+	Chirps                   int64
+	ActiveSessions           int64
+	ExpiredRefreshTokens     int64
+	PendingWebhookDeliveries int64
+}
+
+func (q *Queries) GetStats(ctx context.Context) (GetStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getStats)
+	var i GetStatsRow
+	err := row.Scan(
+		&i.Users,
+		&i.Admins,
+		&i.ChirpyRedUsers,
+		&i.SuspendedUsers,
+		&i.Chirps,
+		&i.ActiveSessions,
+		&i.ExpiredRefreshTokens,
+		&i.PendingWebhookDeliveries,
+	)
+	return i, err
+}
+
+const searchUsersByEmail = `-- name: SearchUsersByEmail :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified, role, suspended_at, totp_last_step
+FROM users
+WHERE email ILIKE '%' || $1::text || '%'
+ORDER BY email
+LIMIT $2
+`
+
+type SearchUsersByEmailParams struct {
+	Query      string
+	MaxResults int32
+}
+
+func (q *Queries) SearchUsersByEmail(ctx context.Context, arg SearchUsersByEmailParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsersByEmail, arg.Query, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.TotpSecret,
+			&i.TotpEnabled,
+			&i.EmailVerified,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.TotpLastStep,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setChirpyRed = `-- name: SetChirpyRed :one
+UPDATE users
+SET is_chirpy_red = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified, role, suspended_at, totp_last_step
+`
+
+type SetChirpyRedParams struct {
+	ID          uuid.UUID
+	IsChirpyRed bool
+}
+
+func (q *Queries) SetChirpyRed(ctx context.Context, arg SetChirpyRedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setChirpyRed, arg.ID, arg.IsChirpyRed)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified, role, suspended_at, totp_last_step
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified, role, suspended_at, totp_last_step
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unsuspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
@@ -0,0 +1,115 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: audit_events.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, created_at, action, outcome, actor_id, target_id, ip_address, user_agent, detail)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7
+)
+`
+
+type CreateAuditEventParams struct {
+	Action    string
+	Outcome   string
+	ActorID   uuid.NullUUID
+	TargetID  uuid.NullUUID
+	IpAddress string
+	UserAgent string
+	Detail    string
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error {
+	_, err := q.db.ExecContext(ctx, createAuditEvent,
+		arg.Action,
+		arg.Outcome,
+		arg.ActorID,
+		arg.TargetID,
+		arg.IpAddress,
+		arg.UserAgent,
+		arg.Detail,
+	)
+	return err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, created_at, action, outcome, actor_id, target_id, ip_address, user_agent, detail
+FROM audit_events
+WHERE ($1::text IS NULL OR action = $1)
+AND ($2::text IS NULL OR outcome = $2)
+AND ($3::uuid IS NULL OR actor_id = $3)
+AND ($4::uuid IS NULL OR target_id = $4)
+AND ($5::timestamp IS NULL OR created_at >= $5)
+AND ($6::timestamp IS NULL OR created_at < $6)
+ORDER BY created_at DESC
+LIMIT $7
+`
+
+type ListAuditEventsParams struct {
+	Action     sql.NullString
+	Outcome    sql.NullString
+	ActorID    uuid.NullUUID
+	TargetID   uuid.NullUUID
+	Since      sql.NullTime
+	Until      sql.NullTime
+	MaxResults int32
+}
+
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditEvents,
+		arg.Action,
+		arg.Outcome,
+		arg.ActorID,
+		arg.TargetID,
+		arg.Since,
+		arg.Until,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Action,
+			&i.Outcome,
+			&i.ActorID,
+			&i.TargetID,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.Detail,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
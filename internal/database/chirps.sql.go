@@ -7,29 +7,106 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const claimUnannouncedChirps = `-- name: ClaimUnannouncedChirps :many
+UPDATE chirps
+SET announced_at = NOW()
+WHERE id IN (
+	SELECT id
+	FROM chirps
+	WHERE announced_at IS NULL
+	AND publish_at <= NOW()
+	ORDER BY publish_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, publish_at, media_path, announced_at
+`
+
+func (q *Queries) ClaimUnannouncedChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, claimUnannouncedChirps, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.PublishAt,
+			&i.MediaPath,
+			&i.AnnouncedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countChirpsSince = `-- name: CountChirpsSince :one
+SELECT COUNT(*)
+FROM chirps
+WHERE user_id = $1
+AND created_at >= $2
+`
+
+type CountChirpsSinceParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CountChirpsSince(ctx context.Context, arg CountChirpsSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, publish_at, announced_at)
 VALUES (
 	gen_random_uuid(),
 	NOW(),
 	NOW(),
 	$1,
-	$2
+	$2,
+	$3,
+	$4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, publish_at, media_path, announced_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body        string
+	UserID      uuid.UUID
+	PublishAt   time.Time
+	AnnouncedAt sql.NullTime
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.PublishAt,
+		arg.AnnouncedAt,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +114,9 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.PublishAt,
+		&i.MediaPath,
+		&i.AnnouncedAt,
 	)
 	return i, err
 }
@@ -52,9 +132,10 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id 
+SELECT id, created_at, updated_at, body, user_id, publish_at, media_path, announced_at 
 FROM chirps
-ORDER BY created_at
+WHERE publish_at <= NOW()
+ORDER BY publish_at
 `
 
 func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
@@ -72,6 +153,9 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.PublishAt,
+			&i.MediaPath,
+			&i.AnnouncedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -87,10 +171,11 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByAuthorID = `-- name: GetChirpsByAuthorID :many
-SELECT id, created_at, updated_at, body, user_id 
+SELECT id, created_at, updated_at, body, user_id, publish_at, media_path, announced_at 
 FROM chirps
 WHERE user_id = $1
-ORDER BY created_at
+AND publish_at <= NOW()
+ORDER BY publish_at
 `
 
 func (q *Queries) GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
@@ -108,6 +193,9 @@ func (q *Queries) GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.PublishAt,
+			&i.MediaPath,
+			&i.AnnouncedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -123,7 +211,7 @@ func (q *Queries) GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]
 }
 
 const getOneChirp = `-- name: GetOneChirp :one
-SELECT id, created_at, updated_at, body, user_id 
+SELECT id, created_at, updated_at, body, user_id, publish_at, media_path, announced_at 
 FROM chirps
 WHERE id = $1
 `
@@ -137,6 +225,90 @@ func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.PublishAt,
+		&i.MediaPath,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const getPublishedChirp = `-- name: GetPublishedChirp :one
+SELECT id, created_at, updated_at, body, user_id, publish_at, media_path, announced_at
+FROM chirps
+WHERE id = $1
+AND publish_at <= NOW()
+`
+
+func (q *Queries) GetPublishedChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getPublishedChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PublishAt,
+		&i.MediaPath,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const setChirpMedia = `-- name: SetChirpMedia :one
+UPDATE chirps
+SET media_path = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, publish_at, media_path, announced_at
+`
+
+type SetChirpMediaParams struct {
+	ID        uuid.UUID
+	MediaPath sql.NullString
+}
+
+func (q *Queries) SetChirpMedia(ctx context.Context, arg SetChirpMediaParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, setChirpMedia, arg.ID, arg.MediaPath)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PublishAt,
+		&i.MediaPath,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, publish_at, media_path, announced_at
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PublishAt,
+		&i.MediaPath,
+		&i.AnnouncedAt,
 	)
 	return i, err
 }
@@ -10,7 +10,7 @@ import (
 )
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red 
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified, role, suspended_at, totp_last_step 
 FROM users
 WHERE email = $1
 `
@@ -25,6 +25,12 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
@@ -0,0 +1,62 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: magic_links.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createMagicLink = `-- name: CreateMagicLink :exec
+INSERT INTO magic_links (id, created_at, user_id, expires_at, used_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	NULL
+)
+`
+
+type CreateMagicLinkParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+func (q *Queries) CreateMagicLink(ctx context.Context, arg CreateMagicLinkParams) error {
+	_, err := q.db.ExecContext(ctx, createMagicLink, arg.ID, arg.UserID, arg.ExpiresAt)
+	return err
+}
+
+const useMagicLink = `-- name: UseMagicLink :one
+UPDATE magic_links SET used_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING id, created_at, user_id, expires_at, used_at
+`
+
+type UseMagicLinkParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) UseMagicLink(ctx context.Context, arg UseMagicLinkParams) (MagicLink, error) {
+	row := q.db.QueryRowContext(ctx, useMagicLink, arg.ID, arg.UserID)
+	var i MagicLink
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
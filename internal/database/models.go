@@ -6,17 +6,117 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Action    string
+	Outcome   string
+	ActorID   uuid.NullUUID
+	TargetID  uuid.NullUUID
+	IpAddress string
+	UserAgent string
+	Detail    string
+}
+
 type Chirp struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Body        string
+	UserID      uuid.UUID
+	PublishAt   time.Time
+	MediaPath   sql.NullString
+	AnnouncedAt sql.NullTime
+}
+
+type InboundWebhook struct {
+	ID          uuid.UUID
+	ReceivedAt  time.Time
+	Source      string
+	Headers     json.RawMessage
+	Payload     string
+	EventID     sql.NullString
+	EventType   sql.NullString
+	Status      string
+	Error       sql.NullString
+	Attempts    int32
+	ProcessedAt sql.NullTime
+}
+
+type MagicLink struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
 	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type OauthAccessToken struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ClientID  string
+	UserID    uuid.UUID
+	Scope     string
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	OwnerID      uuid.UUID
+}
+
+type OidcLoginState struct {
+	State        string
+	CreatedAt    time.Time
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type ProcessedWebhookEvent struct {
+	ID        string
+	CreatedAt time.Time
+	Event     string
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
 }
 
 type RefreshToken struct {
@@ -28,6 +128,24 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Subscription struct {
+	UserID           uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Plan             string
+	Status           string
+	CurrentPeriodEnd sql.NullTime
+}
+
+type TotpChallenge struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UserID         uuid.UUID
+	ExpiresAt      time.Time
+	FailedAttempts int32
+	UsedAt         sql.NullTime
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +153,47 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	TotpSecret     sql.NullString
+	TotpEnabled    bool
+	EmailVerified  bool
+	Role           string
+	SuspendedAt    sql.NullTime
+	TotpLastStep   sql.NullInt64
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Issuer    string
+	Subject   string
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+	Traceparent    string
+}
+
+type WebhookSubscription struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	OwnerID   uuid.NullUUID
+	ClientID  sql.NullString
+	Url       string
+	Secret    string
+	Events    []string
 }
@@ -0,0 +1,232 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: oauth.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createOAuthAccessToken = `-- name: CreateOAuthAccessToken :exec
+INSERT INTO oauth_access_tokens (id, created_at, client_id, user_id, scope, expires_at, revoked_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5,
+	NULL
+)
+`
+
+type CreateOAuthAccessTokenParams struct {
+	ID        uuid.UUID
+	ClientID  string
+	UserID    uuid.UUID
+	Scope     string
+	ExpiresAt time.Time
+}
+
+func (q *Queries) CreateOAuthAccessToken(ctx context.Context, arg CreateOAuthAccessTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createOAuthAccessToken,
+		arg.ID,
+		arg.ClientID,
+		arg.UserID,
+		arg.Scope,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createOAuthAuthorizationCode = `-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, created_at, client_id, user_id, redirect_uri, scope, code_challenge, expires_at, used_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7,
+	NULL
+)
+`
+
+type CreateOAuthAuthorizationCodeParams struct {
+	CodeHash      string
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+func (q *Queries) CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createOAuthAuthorizationCode,
+		arg.CodeHash,
+		arg.ClientID,
+		arg.UserID,
+		arg.RedirectUri,
+		arg.Scope,
+		arg.CodeChallenge,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createOAuthClient = `-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, name, secret_hash, redirect_uris, owner_id)
+VALUES (
+	$1,
+	NOW(),
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5
+)
+RETURNING id, created_at, updated_at, name, secret_hash, redirect_uris, owner_id
+`
+
+type CreateOAuthClientParams struct {
+	ID           string
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	OwnerID      uuid.UUID
+}
+
+func (q *Queries) CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error) {
+	row := q.db.QueryRowContext(ctx, createOAuthClient,
+		arg.ID,
+		arg.Name,
+		arg.SecretHash,
+		pq.Array(arg.RedirectUris),
+		arg.OwnerID,
+	)
+	var i OauthClient
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.SecretHash,
+		pq.Array(&i.RedirectUris),
+		&i.OwnerID,
+	)
+	return i, err
+}
+
+const getOAuthAccessToken = `-- name: GetOAuthAccessToken :one
+SELECT id, created_at, client_id, user_id, scope, expires_at, revoked_at
+FROM oauth_access_tokens
+WHERE id = $1
+`
+
+func (q *Queries) GetOAuthAccessToken(ctx context.Context, id uuid.UUID) (OauthAccessToken, error) {
+	row := q.db.QueryRowContext(ctx, getOAuthAccessToken, id)
+	var i OauthAccessToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ClientID,
+		&i.UserID,
+		&i.Scope,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getOAuthClient = `-- name: GetOAuthClient :one
+SELECT id, created_at, updated_at, name, secret_hash, redirect_uris, owner_id
+FROM oauth_clients
+WHERE id = $1
+`
+
+func (q *Queries) GetOAuthClient(ctx context.Context, id string) (OauthClient, error) {
+	row := q.db.QueryRowContext(ctx, getOAuthClient, id)
+	var i OauthClient
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.SecretHash,
+		pq.Array(&i.RedirectUris),
+		&i.OwnerID,
+	)
+	return i, err
+}
+
+const revokeAllOAuthAccessTokensForUser = `-- name: RevokeAllOAuthAccessTokensForUser :execrows
+UPDATE oauth_access_tokens SET revoked_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllOAuthAccessTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAllOAuthAccessTokensForUser, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeOAuthAccessToken = `-- name: RevokeOAuthAccessToken :exec
+UPDATE oauth_access_tokens SET revoked_at = NOW()
+WHERE id = $1
+AND client_id = $2
+AND revoked_at IS NULL
+`
+
+type RevokeOAuthAccessTokenParams struct {
+	ID       uuid.UUID
+	ClientID string
+}
+
+func (q *Queries) RevokeOAuthAccessToken(ctx context.Context, arg RevokeOAuthAccessTokenParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOAuthAccessToken, arg.ID, arg.ClientID)
+	return err
+}
+
+const useOAuthAuthorizationCode = `-- name: UseOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes SET used_at = NOW()
+WHERE code_hash = $1
+AND client_id = $2
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING code_hash, created_at, client_id, user_id, redirect_uri, scope, code_challenge, expires_at, used_at
+`
+
+type UseOAuthAuthorizationCodeParams struct {
+	CodeHash string
+	ClientID string
+}
+
+func (q *Queries) UseOAuthAuthorizationCode(ctx context.Context, arg UseOAuthAuthorizationCodeParams) (OauthAuthorizationCode, error) {
+	row := q.db.QueryRowContext(ctx, useOAuthAuthorizationCode, arg.CodeHash, arg.ClientID)
+	var i OauthAuthorizationCode
+	err := row.Scan(
+		&i.CodeHash,
+		&i.CreatedAt,
+		&i.ClientID,
+		&i.UserID,
+		&i.RedirectUri,
+		&i.Scope,
+		&i.CodeChallenge,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
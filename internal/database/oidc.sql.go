@@ -0,0 +1,115 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: oidc.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createOIDCLoginState = `-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states (state, created_at, nonce, code_verifier, expires_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4
+)
+`
+
+type CreateOIDCLoginStateParams struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+func (q *Queries) CreateOIDCLoginState(ctx context.Context, arg CreateOIDCLoginStateParams) error {
+	_, err := q.db.ExecContext(ctx, createOIDCLoginState,
+		arg.State,
+		arg.Nonce,
+		arg.CodeVerifier,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createUserIdentity = `-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (id, created_at, user_id, issuer, subject)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3
+)
+`
+
+type CreateUserIdentityParams struct {
+	UserID  uuid.UUID
+	Issuer  string
+	Subject string
+}
+
+func (q *Queries) CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) error {
+	_, err := q.db.ExecContext(ctx, createUserIdentity, arg.UserID, arg.Issuer, arg.Subject)
+	return err
+}
+
+const getUserByIdentity = `-- name: GetUserByIdentity :one
+SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red, users.totp_secret, users.totp_enabled, users.email_verified, users.role, users.suspended_at, users.totp_last_step FROM users
+JOIN user_identities ON users.id = user_identities.user_id
+WHERE user_identities.issuer = $1
+AND user_identities.subject = $2
+`
+
+type GetUserByIdentityParams struct {
+	Issuer  string
+	Subject string
+}
+
+func (q *Queries) GetUserByIdentity(ctx context.Context, arg GetUserByIdentityParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByIdentity, arg.Issuer, arg.Subject)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerified,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const useOIDCLoginState = `-- name: UseOIDCLoginState :one
+DELETE FROM oidc_login_states
+WHERE state = $1
+AND expires_at > NOW()
+RETURNING state, created_at, nonce, code_verifier, expires_at
+`
+
+func (q *Queries) UseOIDCLoginState(ctx context.Context, state string) (OidcLoginState, error) {
+	row := q.db.QueryRowContext(ctx, useOIDCLoginState, state)
+	var i OidcLoginState
+	err := row.Scan(
+		&i.State,
+		&i.CreatedAt,
+		&i.Nonce,
+		&i.CodeVerifier,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
@@ -0,0 +1,445 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: outbound_webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimDueWebhookDeliveries = `-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = $1,
+updated_at = NOW()
+WHERE id IN (
+	SELECT id
+	FROM webhook_deliveries
+	WHERE status IN ('pending', 'retrying')
+	AND next_attempt_at <= NOW()
+	ORDER BY next_attempt_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, delivered_at, traceparent
+`
+
+type ClaimDueWebhookDeliveriesParams struct {
+	LeaseUntil time.Time
+	MaxResults int32
+}
+
+// Pushing next_attempt_at out leases the deliveries to this worker, so a
+// crashed worker's deliveries are picked up again once the lease lapses.
+func (q *Queries) ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueWebhookDeliveries, arg.LeaseUntil, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SubscriptionID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.Traceparent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, updated_at, subscription_id, event_id, event_type, payload, traceparent, status, next_attempt_at)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	'pending',
+	NOW()
+)
+`
+
+type CreateWebhookDeliveryParams struct {
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        string
+	Traceparent    string
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookDelivery,
+		arg.SubscriptionID,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+		arg.Traceparent,
+	)
+	return err
+}
+
+const createWebhookSubscription = `-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, created_at, updated_at, owner_id, client_id, url, secret, events)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4,
+	$5
+)
+RETURNING id, created_at, updated_at, owner_id, client_id, url, secret, events
+`
+
+type CreateWebhookSubscriptionParams struct {
+	OwnerID  uuid.NullUUID
+	ClientID sql.NullString
+	Url      string
+	Secret   string
+	Events   []string
+}
+
+func (q *Queries) CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookSubscription,
+		arg.OwnerID,
+		arg.ClientID,
+		arg.Url,
+		arg.Secret,
+		pq.Array(arg.Events),
+	)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.ClientID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+	)
+	return i, err
+}
+
+const deleteWebhookSubscription = `-- name: DeleteWebhookSubscription :exec
+DELETE FROM webhook_subscriptions
+WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteWebhookSubscription, id)
+	return err
+}
+
+const getWebhookSubscription = `-- name: GetWebhookSubscription :one
+SELECT id, created_at, updated_at, owner_id, client_id, url, secret, events
+FROM webhook_subscriptions
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (WebhookSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookSubscription, id)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.ClientID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+	)
+	return i, err
+}
+
+const getWebhookSubscriptionForOwner = `-- name: GetWebhookSubscriptionForOwner :one
+SELECT id, created_at, updated_at, owner_id, client_id, url, secret, events
+FROM webhook_subscriptions
+WHERE id = $1
+AND (owner_id = $2 OR client_id = $3)
+`
+
+type GetWebhookSubscriptionForOwnerParams struct {
+	ID       uuid.UUID
+	OwnerID  uuid.NullUUID
+	ClientID sql.NullString
+}
+
+func (q *Queries) GetWebhookSubscriptionForOwner(ctx context.Context, arg GetWebhookSubscriptionForOwnerParams) (WebhookSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookSubscriptionForOwner, arg.ID, arg.OwnerID, arg.ClientID)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.ClientID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+	)
+	return i, err
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, created_at, updated_at, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, delivered_at, traceparent
+FROM webhook_deliveries
+WHERE subscription_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListWebhookDeliveriesParams struct {
+	SubscriptionID uuid.UUID
+	Limit          int32
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveries, arg.SubscriptionID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SubscriptionID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.Traceparent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptionsByOwner = `-- name: ListWebhookSubscriptionsByOwner :many
+SELECT id, created_at, updated_at, owner_id, client_id, url, secret, events
+FROM webhook_subscriptions
+WHERE owner_id = $1
+OR client_id = $2
+ORDER BY created_at
+`
+
+type ListWebhookSubscriptionsByOwnerParams struct {
+	OwnerID  uuid.NullUUID
+	ClientID sql.NullString
+}
+
+func (q *Queries) ListWebhookSubscriptionsByOwner(ctx context.Context, arg ListWebhookSubscriptionsByOwnerParams) ([]WebhookSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookSubscriptionsByOwner, arg.OwnerID, arg.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookSubscription
+	for rows.Next() {
+		var i WebhookSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.ClientID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptionsForEvent = `-- name: ListWebhookSubscriptionsForEvent :many
+SELECT id, created_at, updated_at, owner_id, client_id, url, secret, events
+FROM webhook_subscriptions
+WHERE $1::text = ANY(events)
+AND (
+	owner_id = $2::uuid
+	OR client_id IN (
+		SELECT oauth_access_tokens.client_id
+		FROM oauth_access_tokens
+		WHERE oauth_access_tokens.user_id = $2::uuid
+		AND $3::text = ANY(string_to_array(oauth_access_tokens.scope, ' '))
+		AND revoked_at IS NULL
+		AND expires_at > NOW()
+	)
+)
+`
+
+type ListWebhookSubscriptionsForEventParams struct {
+	EventType string
+	UserID    uuid.UUID
+	Scope     sql.NullString
+}
+
+// Users hear about their own events; apps hear about the events of users
+// who currently have a grant for them that includes scope. A NULL scope
+// keeps the event from apps altogether.
+func (q *Queries) ListWebhookSubscriptionsForEvent(ctx context.Context, arg ListWebhookSubscriptionsForEventParams) ([]WebhookSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookSubscriptionsForEvent, arg.EventType, arg.UserID, arg.Scope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookSubscription
+	for rows.Next() {
+		var i WebhookSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.ClientID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookDeliveryAttempt = `-- name: RecordWebhookDeliveryAttempt :exec
+UPDATE webhook_deliveries
+SET status = $1,
+attempts = attempts + 1,
+last_attempt_at = NOW(),
+next_attempt_at = $2,
+response_status = $3,
+last_error = $4,
+delivered_at = CASE WHEN $1::text = 'succeeded' THEN NOW() ELSE NULL END,
+updated_at = NOW()
+WHERE id = $5
+`
+
+type RecordWebhookDeliveryAttemptParams struct {
+	Status         string
+	NextAttemptAt  time.Time
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
+	ID             uuid.UUID
+}
+
+func (q *Queries) RecordWebhookDeliveryAttempt(ctx context.Context, arg RecordWebhookDeliveryAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookDeliveryAttempt,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.ResponseStatus,
+		arg.LastError,
+		arg.ID,
+	)
+	return err
+}
+
+const requeueWebhookDelivery = `-- name: RequeueWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending',
+attempts = 0,
+next_attempt_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+AND subscription_id = $2
+AND status = 'dead'
+RETURNING id, created_at, updated_at, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, delivered_at, traceparent
+`
+
+type RequeueWebhookDeliveryParams struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+}
+
+func (q *Queries) RequeueWebhookDelivery(ctx context.Context, arg RequeueWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, requeueWebhookDelivery, arg.ID, arg.SubscriptionID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SubscriptionID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.Traceparent,
+	)
+	return i, err
+}
@@ -0,0 +1,138 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: subscriptions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const activateSubscription = `-- name: ActivateSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status, current_period_end)
+VALUES (
+	$1,
+	NOW(),
+	NOW(),
+	$2,
+	'active',
+	$3
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+status = 'active',
+current_period_end = EXCLUDED.current_period_end,
+updated_at = NOW()
+RETURNING user_id, created_at, updated_at, plan, status, current_period_end
+`
+
+type ActivateSubscriptionParams struct {
+	UserID           uuid.UUID
+	Plan             string
+	CurrentPeriodEnd sql.NullTime
+}
+
+func (q *Queries) ActivateSubscription(ctx context.Context, arg ActivateSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, activateSubscription, arg.UserID, arg.Plan, arg.CurrentPeriodEnd)
+	var i Subscription
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+	)
+	return i, err
+}
+
+const expireLapsedSubscriptions = `-- name: ExpireLapsedSubscriptions :many
+WITH expired AS (
+	UPDATE subscriptions
+	SET status = 'expired',
+	updated_at = NOW()
+	WHERE status <> 'expired'
+	AND current_period_end IS NOT NULL
+	AND current_period_end < NOW()
+	RETURNING subscriptions.user_id
+)
+UPDATE users
+SET is_chirpy_red = false,
+updated_at = NOW()
+WHERE id IN (SELECT user_id FROM expired)
+RETURNING id
+`
+
+func (q *Queries) ExpireLapsedSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, expireLapsedSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSubscription = `-- name: GetSubscription :one
+SELECT user_id, created_at, updated_at, plan, status, current_period_end
+FROM subscriptions
+WHERE user_id = $1
+`
+
+func (q *Queries) GetSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, getSubscription, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+	)
+	return i, err
+}
+
+const setSubscriptionStatus = `-- name: SetSubscriptionStatus :one
+UPDATE subscriptions
+SET status = $2,
+updated_at = NOW()
+WHERE user_id = $1
+RETURNING user_id, created_at, updated_at, plan, status, current_period_end
+`
+
+type SetSubscriptionStatusParams struct {
+	UserID uuid.UUID
+	Status string
+}
+
+func (q *Queries) SetSubscriptionStatus(ctx context.Context, arg SetSubscriptionStatusParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, setSubscriptionStatus, arg.UserID, arg.Status)
+	var i Subscription
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+	)
+	return i, err
+}
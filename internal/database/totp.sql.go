@@ -0,0 +1,238 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: totp.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const countRecentTOTPFailures = `-- name: CountRecentTOTPFailures :one
+SELECT COALESCE(SUM(failed_attempts), 0)::int
+FROM totp_challenges
+WHERE user_id = $1
+AND created_at > $2
+`
+
+type CountRecentTOTPFailuresParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CountRecentTOTPFailures(ctx context.Context, arg CountRecentTOTPFailuresParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, countRecentTOTPFailures, arg.UserID, arg.CreatedAt)
+	var column_1 int32
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (id, created_at, user_id, code_hash, used_at)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	NULL
+)
+`
+
+type CreateRecoveryCodeParams struct {
+	UserID   uuid.UUID
+	CodeHash string
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createRecoveryCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const createTOTPChallenge = `-- name: CreateTOTPChallenge :exec
+INSERT INTO totp_challenges (id, created_at, user_id, expires_at, failed_attempts, used_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	0,
+	NULL
+)
+`
+
+type CreateTOTPChallengeParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+func (q *Queries) CreateTOTPChallenge(ctx context.Context, arg CreateTOTPChallengeParams) error {
+	_, err := q.db.ExecContext(ctx, createTOTPChallenge, arg.ID, arg.UserID, arg.ExpiresAt)
+	return err
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRecoveryCodes, userID)
+	return err
+}
+
+const deleteStaleTOTPChallenges = `-- name: DeleteStaleTOTPChallenges :exec
+DELETE FROM totp_challenges
+WHERE user_id = $1
+AND created_at < $2
+`
+
+type DeleteStaleTOTPChallengesParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) DeleteStaleTOTPChallenges(ctx context.Context, arg DeleteStaleTOTPChallengesParams) error {
+	_, err := q.db.ExecContext(ctx, deleteStaleTOTPChallenges, arg.UserID, arg.CreatedAt)
+	return err
+}
+
+const enableTOTP = `-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled = true,
+updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) EnableTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, enableTOTP, id)
+	return err
+}
+
+const getTOTPChallenge = `-- name: GetTOTPChallenge :one
+SELECT id, created_at, user_id, expires_at, failed_attempts, used_at
+FROM totp_challenges
+WHERE id = $1
+AND user_id = $2
+`
+
+type GetTOTPChallengeParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetTOTPChallenge(ctx context.Context, arg GetTOTPChallengeParams) (TotpChallenge, error) {
+	row := q.db.QueryRowContext(ctx, getTOTPChallenge, arg.ID, arg.UserID)
+	var i TotpChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.FailedAttempts,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const recordTOTPChallengeFailure = `-- name: RecordTOTPChallengeFailure :exec
+UPDATE totp_challenges
+SET failed_attempts = failed_attempts + 1
+WHERE id = $1
+`
+
+func (q *Queries) RecordTOTPChallengeFailure(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, recordTOTPChallengeFailure, id)
+	return err
+}
+
+const setTOTPLastStep = `-- name: SetTOTPLastStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1
+AND (totp_last_step IS NULL OR totp_last_step < $2)
+`
+
+type SetTOTPLastStepParams struct {
+	ID           uuid.UUID
+	TotpLastStep sql.NullInt64
+}
+
+func (q *Queries) SetTOTPLastStep(ctx context.Context, arg SetTOTPLastStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setTOTPLastStep, arg.ID, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2,
+totp_enabled = false,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type SetTOTPSecretParams struct {
+	ID         uuid.UUID
+	TotpSecret sql.NullString
+}
+
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setTOTPSecret, arg.ID, arg.TotpSecret)
+	return err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :one
+UPDATE recovery_codes SET used_at = NOW()
+WHERE user_id = $1
+AND code_hash = $2
+AND used_at IS NULL
+RETURNING id, created_at, user_id, code_hash, used_at
+`
+
+type UseRecoveryCodeParams struct {
+	UserID   uuid.UUID
+	CodeHash string
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (RecoveryCode, error) {
+	row := q.db.QueryRowContext(ctx, useRecoveryCode, arg.UserID, arg.CodeHash)
+	var i RecoveryCode
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.CodeHash,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const useTOTPChallenge = `-- name: UseTOTPChallenge :execrows
+UPDATE totp_challenges
+SET used_at = NOW()
+WHERE id = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+AND failed_attempts < $2::int
+`
+
+type UseTOTPChallengeParams struct {
+	ID          uuid.UUID
+	MaxAttempts int32
+}
+
+func (q *Queries) UseTOTPChallenge(ctx context.Context, arg UseTOTPChallengeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPChallenge, arg.ID, arg.MaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,210 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: webhook_events.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimWebhookEvent = `-- name: ClaimWebhookEvent :execrows
+INSERT INTO processed_webhook_events (id, created_at, event)
+VALUES (
+	$1,
+	NOW(),
+	$2
+)
+ON CONFLICT (id) DO NOTHING
+`
+
+type ClaimWebhookEventParams struct {
+	ID    string
+	Event string
+}
+
+func (q *Queries) ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimWebhookEvent, arg.ID, arg.Event)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createInboundWebhook = `-- name: CreateInboundWebhook :one
+INSERT INTO inbound_webhooks (id, received_at, source, headers, payload, status)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	'received'
+)
+RETURNING id, received_at, source, headers, payload, event_id, event_type, status, error, attempts, processed_at
+`
+
+type CreateInboundWebhookParams struct {
+	Source  string
+	Headers json.RawMessage
+	Payload string
+}
+
+func (q *Queries) CreateInboundWebhook(ctx context.Context, arg CreateInboundWebhookParams) (InboundWebhook, error) {
+	row := q.db.QueryRowContext(ctx, createInboundWebhook, arg.Source, arg.Headers, arg.Payload)
+	var i InboundWebhook
+	err := row.Scan(
+		&i.ID,
+		&i.ReceivedAt,
+		&i.Source,
+		&i.Headers,
+		&i.Payload,
+		&i.EventID,
+		&i.EventType,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const deleteRejectedInboundWebhooks = `-- name: DeleteRejectedInboundWebhooks :execrows
+DELETE FROM inbound_webhooks
+WHERE status = 'rejected'
+AND received_at < $1
+`
+
+func (q *Queries) DeleteRejectedInboundWebhooks(ctx context.Context, receivedAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRejectedInboundWebhooks, receivedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const finishInboundWebhook = `-- name: FinishInboundWebhook :exec
+UPDATE inbound_webhooks
+SET event_id = $2,
+event_type = $3,
+status = $4,
+error = $5,
+payload = CASE WHEN $4 = 'rejected' THEN LEFT(payload, $6::int) ELSE payload END,
+attempts = attempts + 1,
+processed_at = NOW()
+WHERE id = $1
+`
+
+type FinishInboundWebhookParams struct {
+	ID                   uuid.UUID
+	EventID              sql.NullString
+	EventType            sql.NullString
+	Status               string
+	Error                sql.NullString
+	RejectedPayloadBytes int32
+}
+
+func (q *Queries) FinishInboundWebhook(ctx context.Context, arg FinishInboundWebhookParams) error {
+	_, err := q.db.ExecContext(ctx, finishInboundWebhook,
+		arg.ID,
+		arg.EventID,
+		arg.EventType,
+		arg.Status,
+		arg.Error,
+		arg.RejectedPayloadBytes,
+	)
+	return err
+}
+
+const getInboundWebhook = `-- name: GetInboundWebhook :one
+SELECT id, received_at, source, headers, payload, event_id, event_type, status, error, attempts, processed_at
+FROM inbound_webhooks
+WHERE id = $1
+`
+
+func (q *Queries) GetInboundWebhook(ctx context.Context, id uuid.UUID) (InboundWebhook, error) {
+	row := q.db.QueryRowContext(ctx, getInboundWebhook, id)
+	var i InboundWebhook
+	err := row.Scan(
+		&i.ID,
+		&i.ReceivedAt,
+		&i.Source,
+		&i.Headers,
+		&i.Payload,
+		&i.EventID,
+		&i.EventType,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const listInboundWebhooks = `-- name: ListInboundWebhooks :many
+SELECT id, received_at, source, headers, payload, event_id, event_type, status, error, attempts, processed_at
+FROM inbound_webhooks
+WHERE ($1::text IS NULL OR status = $1)
+AND ($2::text IS NULL OR event_type = $2)
+ORDER BY received_at DESC
+LIMIT $3
+`
+
+type ListInboundWebhooksParams struct {
+	Status     sql.NullString
+	EventType  sql.NullString
+	MaxResults int32
+}
+
+func (q *Queries) ListInboundWebhooks(ctx context.Context, arg ListInboundWebhooksParams) ([]InboundWebhook, error) {
+	rows, err := q.db.QueryContext(ctx, listInboundWebhooks, arg.Status, arg.EventType, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []InboundWebhook
+	for rows.Next() {
+		var i InboundWebhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReceivedAt,
+			&i.Source,
+			&i.Headers,
+			&i.Payload,
+			&i.EventID,
+			&i.EventType,
+			&i.Status,
+			&i.Error,
+			&i.Attempts,
+			&i.ProcessedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markInboundWebhookAuthenticated = `-- name: MarkInboundWebhookAuthenticated :exec
+UPDATE inbound_webhooks
+SET status = 'authenticated'
+WHERE id = $1
+AND status = 'received'
+`
+
+func (q *Queries) MarkInboundWebhookAuthenticated(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markInboundWebhookAuthenticated, id)
+	return err
+}
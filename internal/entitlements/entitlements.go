@@ -0,0 +1,121 @@
+// Package entitlements maps subscription plans to the capabilities they
+// unlock. The mapping is data rather than code so product can change tiers by
+// editing a JSON file.
+package entitlements
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Capabilities are what a plan allows. A false feature flag leaves the
+// feature off.
+type Capabilities struct {
+	// MaxChirpLength must be positive; Validate rejects zero.
+	MaxChirpLength int `json:"max_chirp_length"`
+	// ChirpsPerHour of zero means no limit is enforced.
+	ChirpsPerHour  int  `json:"chirps_per_hour"`
+	EditChirps     bool `json:"edit_chirps"`
+	ScheduleChirps bool `json:"schedule_chirps"`
+	MediaUploads   bool `json:"media_uploads"`
+	// MaxMediaBytes must be positive when MediaUploads is on; Validate
+	// rejects zero. It's ignored otherwise.
+	MaxMediaBytes int64 `json:"max_media_bytes"`
+}
+
+// Catalog holds every plan's capabilities. Users without a plan, or with a
+// plan the catalog doesn't know, get the default plan's.
+type Catalog struct {
+	DefaultPlan string                  `json:"default_plan"`
+	Plans       map[string]Capabilities `json:"plans"`
+}
+
+const (
+	PlanFree      = "free"
+	PlanChirpyRed = "chirpy_red"
+)
+
+// Default is the catalog used when no file is configured.
+func Default() *Catalog {
+	return &Catalog{
+		DefaultPlan: PlanFree,
+		Plans: map[string]Capabilities{
+			PlanFree: {
+				MaxChirpLength: 140,
+				ChirpsPerHour:  30,
+			},
+			PlanChirpyRed: {
+				MaxChirpLength: 500,
+				ChirpsPerHour:  300,
+				EditChirps:     true,
+				ScheduleChirps: true,
+				MediaUploads:   true,
+				MaxMediaBytes:  5 << 20,
+			},
+		},
+	}
+}
+
+// Load reads a catalog in the same JSON shape Catalog marshals to.
+func Load(r io.Reader) (*Catalog, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	catalog := &Catalog{}
+	err := decoder.Decode(catalog)
+	if err != nil {
+		return nil, fmt.Errorf("decoding entitlements: %w", err)
+	}
+
+	err = catalog.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
+
+func LoadFile(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+func (c *Catalog) Validate() error {
+	if len(c.Plans) == 0 {
+		return errors.New("entitlements must define at least one plan")
+	}
+
+	if _, ok := c.Plans[c.DefaultPlan]; !ok {
+		return fmt.Errorf("default plan %q isn't defined", c.DefaultPlan)
+	}
+
+	for name, plan := range c.Plans {
+		if plan.MaxChirpLength < 1 {
+			return fmt.Errorf("plan %q: max_chirp_length must be positive", name)
+		}
+		if plan.ChirpsPerHour < 0 {
+			return fmt.Errorf("plan %q: chirps_per_hour can't be negative", name)
+		}
+		if plan.MediaUploads && plan.MaxMediaBytes < 1 {
+			return fmt.Errorf("plan %q: max_media_bytes must be positive when media uploads are allowed", name)
+		}
+	}
+
+	return nil
+}
+
+// For returns the capabilities of plan, falling back to the default plan.
+func (c *Catalog) For(plan string) Capabilities {
+	if capabilities, ok := c.Plans[plan]; ok {
+		return capabilities
+	}
+	return c.Plans[c.DefaultPlan]
+}
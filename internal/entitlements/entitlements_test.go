@@ -0,0 +1,51 @@
+package entitlements
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("expected default catalog to be valid, got %s", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	catalog, err := Load(strings.NewReader(`{
+		"default_plan": "free",
+		"plans": {
+			"free": {"max_chirp_length": 140},
+			"pro": {"max_chirp_length": 1000, "edit_chirps": true, "media_uploads": true, "max_media_bytes": 1024}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to load catalog: %s", err)
+	}
+
+	if got := catalog.For("pro"); got.MaxChirpLength != 1000 || !got.EditChirps {
+		t.Errorf("unexpected pro capabilities: %+v", got)
+	}
+
+	if got := catalog.For("unknown"); got.MaxChirpLength != 140 || got.EditChirps {
+		t.Errorf("expected unknown plan to fall back to free, got %+v", got)
+	}
+}
+
+func TestLoadRejectsInvalidCatalogs(t *testing.T) {
+	tests := map[string]string{
+		"no plans":          `{"default_plan": "free", "plans": {}}`,
+		"missing default":   `{"default_plan": "gold", "plans": {"free": {"max_chirp_length": 140}}}`,
+		"zero chirp length": `{"default_plan": "free", "plans": {"free": {}}}`,
+		"media without cap": `{"default_plan": "free", "plans": {"free": {"max_chirp_length": 140, "media_uploads": true}}}`,
+		"unknown field":     `{"default_plan": "free", "plans": {"free": {"max_chirp_length": 140, "max_chirp_lenth": 5}}}`,
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(strings.NewReader(input)); err == nil {
+				t.Errorf("expected catalog to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogMailerWritesMessage(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf)
+
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Hello",
+		Body:    "https://example.com/link",
+	})
+	if err != nil {
+		t.Fatalf("failed to send message: %s", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"To: user@example.com", "Subject: Hello", "https://example.com/link"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got %q", want, out)
+		}
+	}
+}
+
+func TestHeaderInjectionIsRejected(t *testing.T) {
+	m := NewLogMailer(&bytes.Buffer{})
+
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com\r\nBcc: someone@example.com",
+		Subject: "Hello",
+	})
+	if err == nil {
+		t.Errorf("expected error for recipient containing a line break")
+	}
+}
+
+func TestSMTPMailerGivesUpOnStalledServer(t *testing.T) {
+	// A server that accepts a connection but never sends its greeting.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	defer func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	m := NewSMTPMailer(host, port, "", "", "chirpy@example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = m.Send(ctx, Message{To: "user@example.com", Subject: "Hello"})
+	if err == nil {
+		t.Fatal("expected the send to a stalled server to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("send took %v, expected it to give up at the context deadline", elapsed)
+	}
+}
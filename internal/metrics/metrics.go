@@ -0,0 +1,218 @@
+// Package metrics collects Chirpy's Prometheus metrics: HTTP traffic per
+// route, database query timings and a handful of business counters.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests no route pattern matched, so 404 scans
+// can't create a series per URL.
+const unmatchedRoute = "unmatched"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	dbDuration   *prometheus.HistogramVec
+
+	ChirpsCreated     prometheus.Counter
+	Logins            *prometheus.CounterVec
+	WebhooksProcessed *prometheus.CounterVec
+	WebhooksDelivered *prometheus.CounterVec
+}
+
+// New returns a Metrics with its own registry, which also carries the
+// standard Go runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests handled, by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time taken to handle HTTP requests, by method and route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_db_query_duration_seconds",
+			Help:    "Time taken by database queries, by query name and outcome.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"query", "outcome"}),
+		ChirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created.",
+		}),
+		Logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts, by outcome.",
+		}, []string{"outcome"}),
+		WebhooksProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_inbound_webhooks_total",
+			Help: "Inbound webhooks handled, by source and final status.",
+		}, []string{"source", "status"}),
+		WebhooksDelivered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_outbound_webhook_attempts_total",
+			Help: "Outbound webhook delivery attempts, by resulting delivery status.",
+		}, []string{"status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbDuration,
+		m.ChirpsCreated,
+		m.Logins,
+		m.WebhooksProcessed,
+		m.WebhooksDelivered,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware records every request handled by next. It must wrap the
+// ServeMux itself: the route label is the pattern the mux matched, which is
+// only known once the mux has served the request.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := NewStatusRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		m.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.Status())).Inc()
+		m.httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// StatusRecorder remembers the status code written through it, for
+// middleware that reports on responses.
+type StatusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// NewStatusRecorder wraps w. Until a status is written it reports 200, which
+// is what net/http sends when a handler writes a body without one.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status returns the status code written so far.
+func (rec *StatusRecorder) Status() int {
+	return rec.status
+}
+
+func (rec *StatusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *StatusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// flushing and deadlines keep working through the recorder.
+func (rec *StatusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// DBTX is the database handle sqlc's generated code runs queries through.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// InstrumentDB wraps db so every query's duration is recorded under the
+// name sqlc gave it.
+func (m *Metrics) InstrumentDB(db DBTX) DBTX {
+	return &instrumentedDB{db: db, metrics: m}
+}
+
+type instrumentedDB struct {
+	db      DBTX
+	metrics *Metrics
+}
+
+func (i *instrumentedDB) observe(query string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		outcome = "error"
+	}
+	i.metrics.dbDuration.WithLabelValues(QueryName(query), outcome).Observe(time.Since(start).Seconds())
+}
+
+func (i *instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := i.db.ExecContext(ctx, query, args...)
+	i.observe(query, start, err)
+	return result, err
+}
+
+func (i *instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+func (i *instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	i.observe(query, start, err)
+	return rows, err
+}
+
+// QueryRowContext only times the round trip that runs the query; scanning
+// the row happens later in the caller.
+func (i *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := i.db.QueryRowContext(ctx, query, args...)
+	i.observe(query, start, row.Err())
+	return row
+}
+
+// QueryName returns the name from the "-- name: X :kind" comment sqlc puts at
+// the start of each generated query, or "unknown" for any other SQL.
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unknown"
+	}
+
+	name, _, _ := strings.Cut(rest, " ")
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"-- name: CreateUser :one\nINSERT INTO users", "CreateUser"},
+		{"-- name: DeleteAllUsers :exec\nDELETE FROM users", "DeleteAllUsers"},
+		{"SELECT 1", "unknown"},
+		{"-- name: ", "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := QueryName(tt.query); got != tt.want {
+			t.Errorf("QueryName(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMiddlewareLabelsByPattern(t *testing.T) {
+	m := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	handler := m.Middleware(mux)
+
+	for _, path := range []string{"/api/chirps/a", "/api/chirps/b"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/chirps", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope/1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope/2", nil))
+
+	want := `
+# HELP chirpy_http_requests_total HTTP requests handled, by method, route pattern and status code.
+# TYPE chirpy_http_requests_total counter
+chirpy_http_requests_total{method="GET",route="GET /api/chirps/{chirpID}",status="404"} 2
+chirpy_http_requests_total{method="GET",route="unmatched",status="404"} 2
+chirpy_http_requests_total{method="POST",route="POST /api/chirps",status="200"} 1
+`
+	err := testutil.CollectAndCompare(m.httpRequests, strings.NewReader(want))
+	if err != nil {
+		t.Error(err)
+	}
+}
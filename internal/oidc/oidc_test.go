@@ -0,0 +1,165 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIssuer is a minimal OpenID provider serving discovery, a JWKS and a
+// token endpoint that returns whatever ID token the test sets.
+type mockIssuer struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	idToken string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	m := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		if !ok || clientID != "chirpy" || secret != "shh" || r.FormValue("code") != "good-code" || r.FormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": m.idToken, "token_type": "Bearer"})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %s", err)
+	}
+	return signed
+}
+
+func (m *mockIssuer) claims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "user-123",
+		"aud":            "chirpy",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "user@example.com",
+		"email_verified": true,
+	}
+}
+
+func TestLoginAgainstMockIssuer(t *testing.T) {
+	m := newMockIssuer(t)
+	ctx := context.Background()
+
+	provider, err := Discover(ctx, m.server.URL, "chirpy", "shh", "http://localhost/callback", m.server.Client())
+	if err != nil {
+		t.Fatalf("discovery failed: %s", err)
+	}
+
+	m.idToken = m.sign(t, m.claims("nonce-1"))
+
+	verifier, _, err := NewPKCE()
+	if err != nil {
+		t.Fatalf("failed to create PKCE pair: %s", err)
+	}
+
+	raw, err := provider.Exchange(ctx, "good-code", verifier)
+	if err != nil {
+		t.Fatalf("exchange failed: %s", err)
+	}
+
+	idToken, err := provider.VerifyIDToken(ctx, raw, "nonce-1")
+	if err != nil {
+		t.Fatalf("verification failed: %s", err)
+	}
+
+	if idToken.Subject != "user-123" || idToken.Email != "user@example.com" || !idToken.EmailVerified {
+		t.Errorf("unexpected ID token: %+v", idToken)
+	}
+
+	if _, err := provider.Exchange(ctx, "bad-code", verifier); err == nil {
+		t.Errorf("expected exchange with a bad code to fail")
+	}
+}
+
+func TestVerifyIDTokenRejectsBadClaims(t *testing.T) {
+	m := newMockIssuer(t)
+	ctx := context.Background()
+
+	provider, err := Discover(ctx, m.server.URL, "chirpy", "shh", "http://localhost/callback", m.server.Client())
+	if err != nil {
+		t.Fatalf("discovery failed: %s", err)
+	}
+
+	cases := map[string]func(jwt.MapClaims){
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"wrong nonce":    func(c jwt.MapClaims) { c["nonce"] = "other" },
+	}
+
+	for name, mutate := range cases {
+		claims := m.claims("nonce-1")
+		mutate(claims)
+
+		if _, err := provider.VerifyIDToken(ctx, m.sign(t, claims), "nonce-1"); err == nil {
+			t.Errorf("%s: expected verification to fail", name)
+		}
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims("nonce-1"))
+	forged.Header["kid"] = "test-key"
+	signed, _ := forged.SignedString(other)
+	if _, err := provider.VerifyIDToken(ctx, signed, "nonce-1"); err == nil {
+		t.Errorf("expected token signed with another key to be rejected")
+	}
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+type fakeDB struct{}
+
+func (fakeDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, nil
+}
+
+func (fakeDB) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, nil
+}
+
+func (fakeDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, nil
+}
+
+func (fakeDB) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+// newCollector starts an in-process OTLP/HTTP collector that hands every
+// span it receives to the returned channel.
+func newCollector(t *testing.T) (*httptest.Server, <-chan *tracepb.Span) {
+	t.Helper()
+
+	spans := make(chan *tracepb.Span, 100)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading export: %v", err)
+			return
+		}
+
+		req := &coltracepb.ExportTraceServiceRequest{}
+		err = proto.Unmarshal(body, req)
+		if err != nil {
+			t.Errorf("decoding export: %v", err)
+			return
+		}
+
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					spans <- span
+				}
+			}
+		}
+
+		resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(resp)
+	}))
+	t.Cleanup(collector.Close)
+
+	return collector, spans
+}
+
+func TestExportContinuesIncomingTrace(t *testing.T) {
+	collector, received := newCollector(t)
+
+	shutdown, err := Setup(context.Background(), Config{
+		Endpoint:    collector.URL,
+		ServiceName: "chirpy-test",
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set("Traceparent", testTraceparent)
+	ctx := Extract(context.Background(), header)
+
+	ctx, span := Start(ctx, "GET /api/chirps")
+	_, err = InstrumentDB(fakeDB{}).ExecContext(ctx, "-- name: DeleteAllUsers :exec\nDELETE FROM users")
+	if err != nil {
+		t.Fatalf("ExecContext: %v", err)
+	}
+	End(span, nil)
+
+	err = shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	// Shutdown waits for the final export, so everything has arrived.
+	spans := map[string]*tracepb.Span{}
+	for len(received) > 0 {
+		span := <-received
+		spans[span.Name] = span
+	}
+
+	request, ok := spans["GET /api/chirps"]
+	if !ok {
+		t.Fatalf("request span wasn't exported, got %v", spans)
+	}
+	query, ok := spans["DeleteAllUsers"]
+	if !ok {
+		t.Fatalf("query span wasn't exported, got %v", spans)
+	}
+
+	if got := hex.EncodeToString(request.TraceId); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("request trace ID = %s, want the incoming trace", got)
+	}
+	if got := hex.EncodeToString(request.ParentSpanId); got != "00f067aa0ba902b7" {
+		t.Errorf("request parent span ID = %s, want the incoming span", got)
+	}
+	if hex.EncodeToString(query.ParentSpanId) != hex.EncodeToString(request.SpanId) {
+		t.Errorf("query span isn't a child of the request span")
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	ctx := WithTraceparent(context.Background(), testTraceparent)
+	if got := Traceparent(ctx); got != testTraceparent {
+		t.Errorf("Traceparent() = %q, want %q", got, testTraceparent)
+	}
+
+	if got := Traceparent(context.Background()); got != "" {
+		t.Errorf("Traceparent() with no span = %q, want empty", got)
+	}
+}
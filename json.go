@@ -2,16 +2,23 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
-func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
+// respondWithError writes a JSON error and logs it, along with the
+// underlying err if there is one, through the request's logger.
+func respondWithError(w http.ResponseWriter, r *http.Request, code int, msg string, err error) {
+	attrs := []any{"status", code, "message", msg}
 	if err != nil {
-		log.Println(err)
+		attrs = append(attrs, "error", err)
 	}
+
+	logger := loggerFromContext(r.Context())
 	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+		logger.Error("Responding with 5XX error", attrs...)
+	} else if err != nil {
+		logger.Info("Responding with error", attrs...)
 	}
 
 	type errorResponse struct {
@@ -27,7 +34,7 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	data, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		slog.Error("Error marshalling JSON", "error", err)
 		w.WriteHeader(500)
 		return
 	}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/metrics"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+const requestLogContextKey contextKey = "request_log"
+
+// requestLog is what the access log learns about a request while it's being
+// handled.
+type requestLog struct {
+	logger *slog.Logger
+	userID uuid.UUID
+	route  string
+}
+
+// newLogger builds the application logger from LOG_FORMAT ("text" by
+// default, or "json") and LOG_LEVEL ("info" by default).
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		err := lvl.UnmarshalText([]byte(level))
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown LOG_FORMAT %q", format)
+	}
+}
+
+// loggerFromContext returns the request-scoped logger set up by
+// middlewareRequestLog, or the default logger outside of a request.
+func loggerFromContext(ctx context.Context) *slog.Logger {
+	if rl, ok := ctx.Value(requestLogContextKey).(*requestLog); ok {
+		return rl.logger
+	}
+	return slog.Default()
+}
+
+// noteRequestUser records who a request authenticated as, for its access
+// log line.
+func noteRequestUser(ctx context.Context, userID uuid.UUID) {
+	if rl, ok := ctx.Value(requestLogContextKey).(*requestLog); ok {
+		rl.userID = userID
+	}
+}
+
+// noteRequestRoute records the route pattern a request matched, for
+// middleware that hands the mux a copy of the request and so is the only one
+// to see the pattern set on it.
+func noteRequestRoute(ctx context.Context, route string) {
+	if rl, ok := ctx.Value(requestLogContextKey).(*requestLog); ok {
+		rl.route = route
+	}
+}
+
+// addRequestLogAttrs adds attributes to every later log line for the
+// request, including its access log line.
+func addRequestLogAttrs(ctx context.Context, args ...any) {
+	if rl, ok := ctx.Value(requestLogContextKey).(*requestLog); ok {
+		rl.logger = rl.logger.With(args...)
+	}
+}
+
+// validRequestID accepts incoming request IDs that are safe to echo back and
+// log: short, and limited to characters common in UUIDs and trace IDs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return strings.IndexFunc(id, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == ':')
+	}) == -1
+}
+
+// middlewareRequestLog gives every request an ID, reusing the caller's
+// X-Request-ID when it's well formed, and a logger carrying it. Once the
+// request is handled it writes one access log line.
+func (cfg *apiConfig) middlewareRequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		rl := &requestLog{logger: cfg.logger.With("request_id", requestID)}
+		r = r.WithContext(context.WithValue(r.Context(), requestLogContextKey, rl))
+		rec := metrics.NewStatusRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if rl.route != "" {
+			route = rl.route
+		}
+
+		attrs := []any{
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"status", rec.Status(),
+			"latency", time.Since(start),
+		}
+		if rl.userID != uuid.Nil {
+			attrs = append(attrs, "user_id", rl.userID)
+		}
+
+		level := slog.LevelInfo
+		if rec.Status() > 499 {
+			level = slog.LevelError
+		}
+		rl.logger.Log(r.Context(), level, "request", attrs...)
+	})
+}
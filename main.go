@@ -1,14 +1,28 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/config"
 	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/entitlements"
+	"github.com/jzetterman/chirpy/internal/mailer"
+	"github.com/jzetterman/chirpy/internal/metrics"
+	"github.com/jzetterman/chirpy/internal/oidc"
+	"github.com/jzetterman/chirpy/internal/tracing"
 
 	_ "github.com/lib/pq"
 )
@@ -19,64 +33,434 @@ type apiConfig struct {
 	database       *database.Queries
 	secret         string
 	polka_key      string
+
+	mailer                   mailer.Mailer
+	baseURL                  string
+	requireEmailVerification bool
+	passwordPolicy           auth.PasswordPolicy
+	oidc                     *oidc.Provider
+	polkaWebhookSecrets      []string
+	entitlements             *entitlements.Catalog
+	mediaDir                 string
+	webhookClient            *http.Client
+	metrics                  *metrics.Metrics
+	logger                   *slog.Logger
+	db                       *sql.DB
+	workers                  *workerMonitor
+	schemaVersion            int64
+	shuttingDown             atomic.Bool
+	background               sync.WaitGroup
 }
 
 func main() {
-	const filepathRoot = "."
-	const port = "8080"
+	envErr := godotenv.Load()
+
+	cfg, args, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%s\n", err)
+		os.Exit(2)
+	}
+
+	logger, err := newLogger(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	if envErr != nil {
+		logger.Debug("No .env file loaded", "error", envErr)
+	}
+
+	if len(args) > 0 && args[0] == "config" {
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "-help") {
+		fmt.Print(cliUsage)
+		return
+	}
+
+	hasher := newPasswordHasher(cfg)
+	auth.SetPasswordHasher(hasher)
+
+	policy, err := newPasswordPolicy(cfg)
+	if err != nil {
+		fatal(logger, "Error configuring password policy", err)
+	}
+
+	catalog := entitlements.Default()
+	if cfg.EntitlementsPath != "" {
+		catalog, err = entitlements.LoadFile(cfg.EntitlementsPath)
+		if err != nil {
+			fatal(logger, "Error loading entitlements", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint:    cfg.OTLPEndpoint,
+		ServiceName: cfg.ServiceName,
+		SampleRatio: cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		fatal(logger, "Error configuring tracing", err)
+	}
+
+	mail, err := newMailer(cfg)
+	if err != nil {
+		fatal(logger, "Error configuring mailer", err)
+	}
+
+	db, err := sql.Open("postgres", cfg.DBURL)
+	if err != nil {
+		fatal(logger, "Error connecting to database", err)
+	}
+	appMetrics := metrics.New()
+	dbQueries := database.New(instrumentDB(appMetrics, db))
 
-	err := godotenv.Load()
+	migrator, err := newMigrator(db)
 	if err != nil {
-		log.Printf("Error loading environment variables: %s", err)
+		fatal(logger, "Error loading migrations", err)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		err := runMigrate(context.Background(), migrator, args[1:], os.Stdout)
+		db.Close()
+		if err != nil {
+			fatal(logger, "Migration failed", err)
+		}
+		return
 	}
 
-	dbURL := os.Getenv("DB_URL")
-	platform := os.Getenv("PLATFORM")
-	secret := os.Getenv("SECRET")
-	polkaKey := os.Getenv("POLKA_KEY")
+	if cfg.AutoMigrate {
+		err := migrateUp(context.Background(), migrator, os.Stderr)
+		if err != nil {
+			fatal(logger, "Error applying migrations", err)
+		}
+	}
 
-	db, err := sql.Open("postgres", dbURL)
+	err = checkSchemaVersion(context.Background(), migrator)
 	if err != nil {
-		log.Printf("Error connecting to database: %s", err)
+		fatal(logger, "Refusing to start", err)
 	}
-	dbQueries := database.New(db)
 
 	apiCfg := apiConfig{
 		fileserverHits: atomic.Int32{},
 		database:       dbQueries,
-		platform:       platform,
-		secret:         secret,
-		polka_key:      polkaKey,
+		platform:       cfg.Platform,
+		secret:         cfg.Secret,
+		polka_key:      cfg.PolkaKey,
+
+		mailer:                   mail,
+		baseURL:                  cfg.BaseURL,
+		requireEmailVerification: cfg.RequireEmailVerification,
+		passwordPolicy:           policy,
+		polkaWebhookSecrets:      cfg.PolkaWebhookSecrets,
+		entitlements:             catalog,
+		mediaDir:                 cfg.MediaDir,
+		webhookClient:            newWebhookClient(cfg.Platform == "dev"),
+		metrics:                  appMetrics,
+		logger:                   logger,
+		db:                       db,
+		workers:                  newWorkerMonitor(),
+		schemaVersion:            latestSchemaVersion(migrator),
 	}
 	apiCfg.database = dbQueries
 
+	if len(args) > 0 {
+		err := apiCfg.runCommand(context.Background(), args, os.Stdin, os.Stdout)
+		shutdownTracing(context.Background())
+		db.Close()
+		if err != nil {
+			fatal(logger, "Command failed", err)
+		}
+		return
+	}
+
+	if issuer := cfg.OIDCIssuer; issuer != "" {
+		// A provider that's down at startup leaves SSO disabled rather than
+		// keeping Chirpy from serving password logins.
+		provider, err := oidc.Discover(
+			context.Background(),
+			issuer,
+			cfg.OIDCClientID,
+			cfg.OIDCClientSecret,
+			apiCfg.baseURL+"/api/login/oidc/callback",
+			nil,
+		)
+		if err != nil {
+			logger.Error("Error configuring OpenID Connect provider", "issuer", issuer, "error", err)
+		} else {
+			apiCfg.oidc = provider
+		}
+	}
+
+	// Workers keep running while requests drain, since those requests may
+	// still queue work for them, and are stopped once the server is done.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	workers := sync.WaitGroup{}
+	startWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+	startWorker(func(ctx context.Context) { apiCfg.runSubscriptionExpiry(ctx, time.Minute) })
+	startWorker(func(ctx context.Context) { apiCfg.runWebhookDispatcher(ctx, 5*time.Second) })
+	startWorker(func(ctx context.Context) { apiCfg.runWebhookLogRetention(ctx, time.Hour) })
+	startWorker(func(ctx context.Context) { apiCfg.runChirpAnnouncer(ctx, 30*time.Second) })
+
 	mux := http.NewServeMux()
-	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
+	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(cfg.FilepathRoot))))
 	mux.Handle("/app/", fsHandler)
-	mux.HandleFunc("GET /api/healthz", readinessHandler)
+	mux.HandleFunc("GET /api/healthz", healthzHandler)
+	mux.HandleFunc("GET /api/livez", livezHandler)
+	mux.HandleFunc("GET /api/readyz", apiCfg.readyzHandler)
+	// Scrapers can't sign in, so they're given a separate listener kept off
+	// the public network. Without one only admins can read the metrics.
+	if cfg.MetricsAddr == "" {
+		mux.Handle("GET /metrics", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.metrics.Handler()))
+	}
 
-	mux.HandleFunc("GET /admin/metrics", apiCfg.reportingHandler)
-	mux.HandleFunc("POST /admin/reset", apiCfg.resetHandler)
+	// Admin routes are registered on the main mux, rather than a nested one,
+	// so each keeps its own route pattern in the request metrics.
+	admin := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, apiCfg.middlewareRequireRole(roleAdmin, handler))
+	}
+	admin("GET /admin/metrics", apiCfg.reportingHandler)
+	admin("POST /admin/reset", apiCfg.resetHandler)
+	admin("GET /admin/audit-events", apiCfg.adminAuditEventsHandler)
+	admin("GET /admin/users", apiCfg.adminUsersSearchHandler)
+	admin("GET /admin/users/{userID}", apiCfg.adminUserGetHandler)
+	admin("DELETE /admin/users/{userID}", apiCfg.adminUserDeleteHandler)
+	admin("POST /admin/users/{userID}/suspend", apiCfg.adminUserSuspendHandler)
+	admin("POST /admin/users/{userID}/unsuspend", apiCfg.adminUserUnsuspendHandler)
+	admin("POST /admin/users/{userID}/password-reset", apiCfg.adminUserPasswordResetHandler)
+	admin("PUT /admin/users/{userID}/chirpy-red", apiCfg.adminUserChirpyRedHandler)
+	admin("GET /admin/webhooks", apiCfg.adminWebhooksListHandler)
+	admin("GET /admin/webhooks/{webhookID}", apiCfg.adminWebhookGetHandler)
+	admin("POST /admin/webhooks/{webhookID}/replay", apiCfg.adminWebhookReplayHandler)
 
 	mux.HandleFunc("GET /api/chirps", apiCfg.chirpsGetHandler)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.chirpGetHandler)
 	mux.HandleFunc("POST /api/chirps", apiCfg.chirpsPostHandler)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.chirpUpdateHandler)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/media", apiCfg.chirpMediaUploadHandler)
+	mux.Handle("GET "+mediaURLPrefix, apiCfg.mediaHandler())
+	mux.HandleFunc("GET /api/entitlements", apiCfg.entitlementsHandler)
 
 	mux.HandleFunc("POST /api/users", apiCfg.createNewUser)
 	mux.HandleFunc("PUT /api/users", apiCfg.userUpdateHandler)
+	mux.HandleFunc("PATCH /api/users", apiCfg.userUpdateHandler)
+	mux.HandleFunc("POST /api/users/totp", apiCfg.totpEnrollHandler)
+	mux.HandleFunc("POST /api/users/totp/confirm", apiCfg.totpConfirmHandler)
+
+	mux.HandleFunc("GET /api/verify-email", apiCfg.verifyEmailHandler)
+	mux.HandleFunc("POST /api/verify-email", apiCfg.resendVerificationHandler)
+
+	mux.HandleFunc("POST /api/password-reset/request", apiCfg.passwordResetRequestHandler)
+	mux.HandleFunc("POST /api/password-reset/confirm", apiCfg.passwordResetConfirmHandler)
 
 	mux.HandleFunc("POST /api/login", apiCfg.loginHandler)
+	mux.HandleFunc("POST /api/login/totp", apiCfg.loginTOTPHandler)
+	mux.HandleFunc("POST /api/login/magic", apiCfg.magicLinkRequestHandler)
+	mux.HandleFunc("GET /api/login/magic", apiCfg.magicLinkLoginHandler)
+	mux.HandleFunc("GET /api/login/oidc", apiCfg.oidcLoginHandler)
+	mux.HandleFunc("GET /api/login/oidc/callback", apiCfg.oidcCallbackHandler)
 	mux.HandleFunc("POST /api/refresh", apiCfg.refreshUserToken)
 	mux.HandleFunc("POST /api/revoke", apiCfg.revokeUserToken)
 
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.oauthClientCreateHandler)
+	mux.HandleFunc("GET /api/oauth/authorize", apiCfg.oauthAuthorizeHandler)
+	mux.HandleFunc("POST /api/oauth/authorize", apiCfg.oauthAuthorizeSubmitHandler)
+	mux.HandleFunc("POST /api/oauth/token", apiCfg.oauthTokenHandler)
+	mux.HandleFunc("POST /api/oauth/introspect", apiCfg.oauthIntrospectHandler)
+	mux.HandleFunc("POST /api/oauth/revoke", apiCfg.oauthRevokeHandler)
+
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.chirpyRedHandler)
 
+	mux.HandleFunc("POST /api/webhooks", apiCfg.webhookSubscriptionCreateHandler)
+	mux.HandleFunc("GET /api/webhooks", apiCfg.webhookSubscriptionsListHandler)
+	mux.HandleFunc("DELETE /api/webhooks/{subscriptionID}", apiCfg.webhookSubscriptionDeleteHandler)
+	mux.HandleFunc("GET /api/webhooks/{subscriptionID}/deliveries", apiCfg.webhookDeliveriesListHandler)
+	mux.HandleFunc("POST /api/webhooks/{subscriptionID}/deliveries/{deliveryID}/redeliver", apiCfg.webhookRedeliverHandler)
+
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:    ":" + cfg.Port,
+		Handler: apiCfg.middlewareRequestLog(apiCfg.middlewareTrace(apiCfg.metrics.Middleware(mux))),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Restore the default handling so a second signal exits at once.
+		<-ctx.Done()
+		stop()
+		apiCfg.shuttingDown.Store(true)
+	}()
+
+	if cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", apiCfg.metrics.Handler())
+		metricsSrv := &http.Server{Addr: cfg.MetricsAddr, Handler: metricsMux}
+		defer metricsSrv.Close()
+
+		go func() {
+			err := metricsSrv.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal(logger, "Metrics server stopped", err)
+			}
+		}()
+		logger.Info("Serving metrics", "addr", cfg.MetricsAddr)
+	}
+
+	logger.Info("Serving", "port", cfg.Port, "profile", cfg.Profile)
+	err = serve(ctx, logger, srv, cfg.ShutdownDelay, cfg.ShutdownTimeout)
+	if err != nil {
+		fatal(logger, "Server stopped", err)
+	}
+
+	// The same timeout bounds stopping the workers and flushing traces.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		apiCfg.background.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		logger.Warn("Timed out waiting for background workers")
+	}
+
+	err = shutdownTracing(shutdownCtx)
+	if err != nil {
+		logger.Error("Error flushing traces", "error", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		logger.Error("Error closing database", "error", err)
+	}
+
+	logger.Info("Shut down")
+}
+
+// serve runs srv until ctx is done, keeps serving for delay while readyz
+// reports the shutdown, then stops accepting connections and waits up to
+// timeout for in-flight requests to finish. Connections still
+// open after that, such as slow or long-lived streaming clients, are closed.
+// It only returns an error if the server couldn't run at all.
+func serve(ctx context.Context, logger *slog.Logger, srv *http.Server, delay, timeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Shutdown closes the listener straight away, so keep serving while
+	// readyz fails until load balancers have noticed.
+	if delay > 0 {
+		logger.Info("Shutting down, failing readiness before draining", "delay", delay)
+		select {
+		case err := <-serveErr:
+			return err
+		case <-time.After(delay):
+		}
+	}
+
+	logger.Info("Shutting down, draining connections", "timeout", timeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := srv.Shutdown(drainCtx)
+	if err != nil {
+		logger.Warn("Connections still open after the drain timeout, closing them", "error", err)
+		srv.Close()
+	}
+
+	return nil
+}
+
+// fatal logs err and exits.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// newMailer picks the mail transport from MAILER. Anything other than "smtp"
+// logs messages instead of sending them, to MAIL_LOG_PATH if it's set.
+func newMailer(cfg *config.Config) (mailer.Mailer, error) {
+	if cfg.Mailer == "smtp" {
+		return mailer.NewSMTPMailer(
+			cfg.SMTPHost,
+			cfg.SMTPPort,
+			cfg.SMTPUsername,
+			cfg.SMTPPassword,
+			cfg.SMTPFrom,
+		), nil
+	}
+
+	if cfg.MailLogPath == "" {
+		return mailer.NewLogMailer(os.Stdout), nil
+	}
+
+	f, err := os.OpenFile(cfg.MailLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return mailer.NewLogMailer(f), nil
+}
+
+// newPasswordHasher builds the configured hasher. Argon2id settings left at
+// zero keep auth.DefaultArgon2idParams.
+func newPasswordHasher(cfg *config.Config) auth.PasswordHasher {
+	if cfg.PasswordHasher == "bcrypt" {
+		return auth.BcryptHasher{Cost: cfg.BcryptCost}
+	}
+
+	params := auth.DefaultArgon2idParams
+	if cfg.Argon2MemoryKiB != 0 {
+		params.Memory = cfg.Argon2MemoryKiB
+	}
+	if cfg.Argon2Iterations != 0 {
+		params.Iterations = cfg.Argon2Iterations
+	}
+	if cfg.Argon2Parallelism != 0 {
+		params.Parallelism = cfg.Argon2Parallelism
+	}
+
+	return auth.NewArgon2idHasher(params)
+}
+
+// newPasswordPolicy builds the password policy. The breach check is skipped
+// when no dataset is configured.
+func newPasswordPolicy(cfg *config.Config) (auth.PasswordPolicy, error) {
+	policy := auth.PasswordPolicy{
+		MinLength:      cfg.PasswordMinLength,
+		MinEntropyBits: cfg.PasswordMinEntropyBits,
+	}
+
+	if cfg.BreachedPasswordsPath != "" {
+		breached, err := auth.OpenBreachedPasswords(cfg.BreachedPasswordsPath)
+		if err != nil {
+			return auth.PasswordPolicy{}, err
+		}
+		policy.Breached = breached
 	}
 
-	log.Printf("Serving on port: %s\n", port)
-	log.Fatal(srv.ListenAndServe())
+	return policy, nil
 }
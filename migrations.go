@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+//go:embed sql/schema/*.sql
+var embeddedMigrations embed.FS
+
+// newMigrator returns a goose provider for the migrations built into the
+// binary. Running migrations takes a Postgres advisory lock, so instances
+// starting at the same time apply them one at a time instead of racing.
+func newMigrator(db *sql.DB) (*goose.Provider, error) {
+	migrations, err := fs.Sub(embeddedMigrations, "sql/schema")
+	if err != nil {
+		return nil, err
+	}
+
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+
+	return goose.NewProvider(goose.DialectPostgres, db, migrations,
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+}
+
+// latestSchemaVersion is the version of the newest embedded migration,
+// which is the schema this build expects.
+func latestSchemaVersion(migrator *goose.Provider) int64 {
+	sources := migrator.ListSources()
+	if len(sources) == 0 {
+		return 0
+	}
+	return sources[len(sources)-1].Version
+}
+
+// checkSchemaVersion returns an error unless the database schema is exactly
+// the one this build expects.
+func checkSchemaVersion(ctx context.Context, migrator *goose.Provider) error {
+	current, err := migrator.GetDBVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't read the schema version: %w", err)
+	}
+
+	expected := latestSchemaVersion(migrator)
+	switch {
+	case current < expected:
+		return fmt.Errorf("database schema is at version %d but this build needs %d; run `chirpy migrate up` or set AUTO_MIGRATE", current, expected)
+	case current > expected:
+		return fmt.Errorf("database schema is at version %d, newer than the %d this build knows about", current, expected)
+	}
+	return nil
+}
+
+// migrateUp applies every pending migration, reporting each one to w.
+func migrateUp(ctx context.Context, migrator *goose.Provider, w io.Writer) error {
+	results, err := migrator.Up(ctx)
+	for _, result := range results {
+		if result.Error == nil {
+			fmt.Fprintf(w, "Applied %s in %s\n", result.Source.Path, result.Duration.Round(time.Millisecond))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(w, "Schema is up to date")
+	}
+	return nil
+}
+
+// runMigrate handles `chirpy migrate up|down|status`. Down rolls back the
+// most recent migration only.
+func runMigrate(ctx context.Context, migrator *goose.Provider, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: chirpy migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, migrator, w)
+	case "down":
+		result, err := migrator.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			fmt.Fprintln(w, "No migrations to roll back")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "Rolled back %s in %s\n", result.Source.Path, result.Duration.Round(time.Millisecond))
+		return nil
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tMIGRATION")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if status.State == goose.StateApplied {
+				appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
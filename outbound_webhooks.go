@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserUpgraded = "user.upgraded"
+)
+
+// webhookEvents are the events outbound webhook subscriptions can ask for,
+// each with the scope an app must hold a grant for to hear about a user's
+// events. Events without one, such as billing changes, only go to the
+// user's own subscriptions.
+var webhookEvents = map[string]string{
+	eventChirpCreated: scopeChirpsWrite,
+	eventChirpDeleted: scopeChirpsDelete,
+	eventUserUpgraded: "",
+}
+
+const (
+	deliveryPending   = "pending"
+	deliveryRetrying  = "retrying"
+	deliverySucceeded = "succeeded"
+	deliveryDead      = "dead"
+)
+
+const (
+	webhookMaxAttempts     = 8
+	webhookBaseBackoff     = 30 * time.Second
+	webhookMaxBackoff      = 6 * time.Hour
+	webhookDeliveryTimeout = 10 * time.Second
+	webhookDeliveryLease   = 2 * time.Minute
+	webhookBatchSize       = 20
+)
+
+// emitEvent queues an event about userID for every subscription entitled to
+// hear about it. Failures are only logged; a webhook is never a reason to fail
+// the request that caused it.
+func (cfg *apiConfig) emitEvent(ctx context.Context, eventType string, userID uuid.UUID, data any) {
+	subscriptions, err := cfg.database.ListWebhookSubscriptionsForEvent(ctx, database.ListWebhookSubscriptionsForEventParams{
+		EventType: eventType,
+		UserID:    userID,
+		Scope:     sql.NullString{String: webhookEvents[eventType], Valid: webhookEvents[eventType] != ""},
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("Error finding webhook subscriptions", "event_type", eventType, "error", err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	eventID := uuid.New()
+	payload, err := json.Marshal(struct {
+		ID        uuid.UUID `json:"id"`
+		Type      string    `json:"type"`
+		CreatedAt time.Time `json:"created_at"`
+		Data      any       `json:"data"`
+	}{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("Error encoding webhook payload", "event_type", eventType, "error", err)
+		return
+	}
+
+	// Deliveries happen later, outside of this request, so its trace context
+	// is stored with them for the receiver to carry on.
+	traceparent := tracing.Traceparent(ctx)
+	for _, subscription := range subscriptions {
+		err = cfg.database.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			SubscriptionID: subscription.ID,
+			EventID:        eventID,
+			EventType:      eventType,
+			Payload:        string(payload),
+			Traceparent:    traceparent,
+		})
+		if err != nil {
+			loggerFromContext(ctx).Error("Error queueing webhook", "event_type", eventType, "subscription_id", subscription.ID, "error", err)
+		}
+	}
+}
+
+// webhookBackoff is how long to wait after a delivery's attempt-th failure:
+// exponential from webhookBaseBackoff, capped, with up to 10% jitter so
+// retries to one receiver don't arrive in lockstep.
+func webhookBackoff(attempt int) time.Duration {
+	backoff := webhookMaxBackoff
+	if attempt < 30 {
+		backoff = min(webhookBaseBackoff<<(attempt-1), webhookMaxBackoff)
+	}
+	return backoff + rand.N(backoff/10+1)
+}
+
+// runWebhookDispatcher delivers due webhooks every interval until ctx is
+// done.
+func (cfg *apiConfig) runWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	cfg.workers.started(workerWebhookDispatcher, interval)
+
+	for {
+		err := cfg.dispatchDueWebhooks(ctx)
+		cfg.workers.ran(workerWebhookDispatcher, err)
+		if err != nil {
+			cfg.logger.Error("Error dispatching webhooks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) dispatchDueWebhooks(ctx context.Context) error {
+	deliveries, err := cfg.database.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: time.Now().UTC().Add(webhookDeliveryLease),
+		MaxResults: webhookBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Claimed deliveries are finished even if ctx is canceled by a shutdown,
+	// rather than cut off mid-request; each is bounded by its own timeout.
+	deliverCtx := context.WithoutCancel(ctx)
+	wg := sync.WaitGroup{}
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg.deliverWebhook(deliverCtx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// deliverWebhook makes one attempt at a delivery and records the outcome.
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, delivery database.WebhookDelivery) {
+	subscription, err := cfg.database.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		cfg.logger.Error("Error loading webhook subscription", "subscription_id", delivery.SubscriptionID, "error", err)
+		return
+	}
+
+	responseStatus, err := cfg.sendWebhook(ctx, subscription, delivery)
+
+	params := database.RecordWebhookDeliveryAttemptParams{
+		ID:             delivery.ID,
+		Status:         deliverySucceeded,
+		NextAttemptAt:  time.Now().UTC(),
+		ResponseStatus: sql.NullInt32{Int32: int32(responseStatus), Valid: responseStatus != 0},
+	}
+	if err != nil {
+		attempts := int(delivery.Attempts) + 1
+		params.LastError = sql.NullString{String: err.Error(), Valid: true}
+		params.Status = deliveryRetrying
+		params.NextAttemptAt = time.Now().UTC().Add(webhookBackoff(attempts))
+		if attempts >= webhookMaxAttempts {
+			params.Status = deliveryDead
+		}
+	}
+
+	cfg.metrics.WebhooksDelivered.WithLabelValues(params.Status).Inc()
+
+	err = cfg.database.RecordWebhookDeliveryAttempt(ctx, params)
+	if err != nil {
+		cfg.logger.Error("Error recording webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// sendWebhook POSTs a delivery's payload, signed with the subscription's
+// secret the same way Polka signs the webhooks it sends us. The request
+// continues the trace of the request that caused the event.
+func (cfg *apiConfig) sendWebhook(ctx context.Context, subscription database.WebhookSubscription, delivery database.WebhookDelivery) (status int, err error) {
+	ctx, span := tracing.Start(tracing.WithTraceparent(ctx, delivery.Traceparent), "webhook.deliver "+delivery.EventType,
+		trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, webhookDeliveryTimeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks")
+	req.Header.Set("X-Chirpy-Event", delivery.EventType)
+	req.Header.Set("X-Chirpy-Delivery", delivery.ID.String())
+	req.Header.Set("X-Chirpy-Timestamp", fmt.Sprint(now.Unix()))
+	req.Header.Set("X-Chirpy-Signature", auth.SignWebhook(subscription.Secret, now, body))
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := cfg.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+var errPrivateWebhookAddress = errors.New("webhook receivers can't be on private or loopback addresses")
+
+// newWebhookClient returns the client used for outbound webhooks. Unless
+// allowPrivate is set, it refuses to connect to loopback, private and
+// link-local addresses so subscribers can't aim Chirpy at internal services.
+// The check runs on the address actually dialed, after DNS resolution and
+// on every redirect.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookDeliveryTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if allowPrivate {
+				return nil
+			}
+
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+				return errPrivateWebhookAddress
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   webhookDeliveryTimeout,
+	}
+}
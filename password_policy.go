@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jzetterman/chirpy/internal/auth"
+)
+
+// validatePassword checks password against the configured policy. When it's
+// rejected, a 400 listing every violation is written and false is returned.
+func (cfg *apiConfig) validatePassword(w http.ResponseWriter, r *http.Request, password string, userInputs ...string) bool {
+	type response struct {
+		Error      string                   `json:"error"`
+		Violations []auth.PasswordViolation `json:"violations"`
+	}
+
+	err := cfg.passwordPolicy.Validate(password, userInputs...)
+	if err == nil {
+		return true
+	}
+
+	var policyErr *auth.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		respondWithJSON(w, http.StatusBadRequest, response{
+			Error:      "Password doesn't meet the password policy",
+			Violations: policyErr.Violations,
+		})
+		return false
+	}
+
+	respondWithError(w, r, http.StatusInternalServerError, "Couldn't check password", err)
+	return false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/jzetterman/chirpy/internal/auth"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// roleRanks orders the roles so that each one includes the permissions of
+// those below it.
+var roleRanks = map[string]int{
+	roleUser:      0,
+	roleModerator: 1,
+	roleAdmin:     2,
+}
+
+func hasRole(actual, required string) bool {
+	actualRank, ok := roleRanks[actual]
+	if !ok {
+		return false
+	}
+	return actualRank >= roleRanks[required]
+}
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// userFromContext returns the user stored by middlewareRequireRole.
+func userFromContext(ctx context.Context) (database.User, bool) {
+	user, ok := ctx.Value(userContextKey).(database.User)
+	return user, ok
+}
+
+// middlewareRequireRole only lets through requests with a first-party access
+// token for a user holding at least role. The role is read from the database
+// on every request so demotions and suspensions take effect immediately.
+func (cfg *apiConfig) middlewareRequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(r.Context(), "auth.require_role")
+		span.SetAttributes(attribute.String("chirpy.required_role", role))
+
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			tracing.End(span, err)
+			respondWithError(w, r, http.StatusUnauthorized, "Couldn't find token", err)
+			return
+		}
+
+		userID, err := auth.ValidateJWT(token, cfg.secret)
+		if err != nil {
+			tracing.End(span, err)
+			respondWithError(w, r, http.StatusUnauthorized, "Unable to verify token", err)
+			return
+		}
+		noteRequestUser(r.Context(), userID)
+
+		user, err := cfg.database.GetUserByID(ctx, userID)
+		if err != nil {
+			tracing.End(span, err)
+			respondWithError(w, r, http.StatusUnauthorized, "Couldn't find user", err)
+			return
+		}
+
+		if user.SuspendedAt.Valid {
+			tracing.End(span, errAccountSuspended)
+			respondWithError(w, r, http.StatusForbidden, "This account has been suspended", nil)
+			return
+		}
+
+		if !hasRole(user.Role, role) {
+			tracing.End(span, errors.New("user doesn't hold the required role"))
+			respondWithError(w, r, http.StatusForbidden, "You don't have permission to do that", nil)
+			return
+		}
+
+		// The span stays open around the handler so its work is traced as
+		// part of the admin request it authorized.
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, userContextKey, user)))
+		tracing.End(span, nil)
+	})
+}
@@ -0,0 +1,49 @@
+-- name: SearchUsersByEmail :many
+SELECT *
+FROM users
+WHERE email ILIKE '%' || sqlc.arg('query')::text || '%'
+ORDER BY email
+LIMIT sqlc.arg('max_results');
+
+-- name: CountActiveSessions :one
+SELECT COUNT(*)
+FROM refresh_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND expires_at > NOW();
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetChirpyRed :one
+UPDATE users
+SET is_chirpy_red = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: GetStats :one
+SELECT
+	(SELECT COUNT(*) FROM users) AS users,
+	(SELECT COUNT(*) FROM users WHERE role = 'admin') AS admins,
+	(SELECT COUNT(*) FROM users WHERE is_chirpy_red) AS chirpy_red_users,
+	(SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL) AS suspended_users,
+	(SELECT COUNT(*) FROM chirps) AS chirps,
+	(SELECT COUNT(*) FROM refresh_tokens WHERE revoked_at IS NULL AND expires_at > NOW()) AS active_sessions,
+	(SELECT COUNT(*) FROM refresh_tokens WHERE expires_at < NOW()) AS expired_refresh_tokens,
+	(SELECT COUNT(*) FROM webhook_deliveries WHERE status IN ('pending', 'retrying')) AS pending_webhook_deliveries;
@@ -1,30 +1,74 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, publish_at, announced_at)
 VALUES (
 	gen_random_uuid(),
 	NOW(),
 	NOW(),
 	$1,
-	$2
+	$2,
+	$3,
+	$4
 )
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT * 
 FROM chirps
-ORDER BY created_at;
+WHERE publish_at <= NOW()
+ORDER BY publish_at;
 
 -- name: GetChirpsByAuthorID :many
 SELECT * 
 FROM chirps
 WHERE user_id = $1
-ORDER BY created_at;
+AND publish_at <= NOW()
+ORDER BY publish_at;
 
 -- name: GetOneChirp :one
 SELECT * 
 FROM chirps
 WHERE id = $1;
 
+-- name: GetPublishedChirp :one
+SELECT *
+FROM chirps
+WHERE id = $1
+AND publish_at <= NOW();
+
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetChirpMedia :one
+UPDATE chirps
+SET media_path = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CountChirpsSince :one
+SELECT COUNT(*)
+FROM chirps
+WHERE user_id = $1
+AND created_at >= $2;
+
+-- name: ClaimUnannouncedChirps :many
+UPDATE chirps
+SET announced_at = NOW()
+WHERE id IN (
+	SELECT id
+	FROM chirps
+	WHERE announced_at IS NULL
+	AND publish_at <= NOW()
+	ORDER BY publish_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
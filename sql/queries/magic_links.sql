@@ -0,0 +1,17 @@
+-- name: CreateMagicLink :exec
+INSERT INTO magic_links (id, created_at, user_id, expires_at, used_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	NULL
+);
+
+-- name: UseMagicLink :one
+UPDATE magic_links SET used_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
@@ -0,0 +1,67 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, name, secret_hash, redirect_uris, owner_id)
+VALUES (
+	$1,
+	NOW(),
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT *
+FROM oauth_clients
+WHERE id = $1;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, created_at, client_id, user_id, redirect_uri, scope, code_challenge, expires_at, used_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7,
+	NULL
+);
+
+-- name: UseOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes SET used_at = NOW()
+WHERE code_hash = $1
+AND client_id = $2
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: CreateOAuthAccessToken :exec
+INSERT INTO oauth_access_tokens (id, created_at, client_id, user_id, scope, expires_at, revoked_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5,
+	NULL
+);
+
+-- name: GetOAuthAccessToken :one
+SELECT *
+FROM oauth_access_tokens
+WHERE id = $1;
+
+-- name: RevokeOAuthAccessToken :exec
+UPDATE oauth_access_tokens SET revoked_at = NOW()
+WHERE id = $1
+AND client_id = $2
+AND revoked_at IS NULL;
+
+-- name: RevokeAllOAuthAccessTokensForUser :execrows
+UPDATE oauth_access_tokens SET revoked_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
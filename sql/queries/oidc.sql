@@ -0,0 +1,31 @@
+-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states (state, created_at, nonce, code_verifier, expires_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4
+);
+
+-- name: UseOIDCLoginState :one
+DELETE FROM oidc_login_states
+WHERE state = $1
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: GetUserByIdentity :one
+SELECT users.* FROM users
+JOIN user_identities ON users.id = user_identities.user_id
+WHERE user_identities.issuer = $1
+AND user_identities.subject = $2;
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (id, created_at, user_id, issuer, subject)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3
+);
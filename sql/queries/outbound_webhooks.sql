@@ -0,0 +1,116 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, created_at, updated_at, owner_id, client_id, url, secret, events)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4,
+	$5
+)
+RETURNING *;
+
+-- name: ListWebhookSubscriptionsByOwner :many
+SELECT *
+FROM webhook_subscriptions
+WHERE owner_id = sqlc.narg('owner_id')
+OR client_id = sqlc.narg('client_id')
+ORDER BY created_at;
+
+-- name: GetWebhookSubscriptionForOwner :one
+SELECT *
+FROM webhook_subscriptions
+WHERE id = sqlc.arg('id')
+AND (owner_id = sqlc.narg('owner_id') OR client_id = sqlc.narg('client_id'));
+
+-- name: GetWebhookSubscription :one
+SELECT *
+FROM webhook_subscriptions
+WHERE id = $1;
+
+-- name: DeleteWebhookSubscription :exec
+DELETE FROM webhook_subscriptions
+WHERE id = $1;
+
+-- name: ListWebhookSubscriptionsForEvent :many
+-- Users hear about their own events; apps hear about the events of users
+-- who currently have a grant for them that includes scope. A NULL scope
+-- keeps the event from apps altogether.
+SELECT *
+FROM webhook_subscriptions
+WHERE sqlc.arg('event_type')::text = ANY(events)
+AND (
+	owner_id = sqlc.arg('user_id')::uuid
+	OR client_id IN (
+		SELECT oauth_access_tokens.client_id
+		FROM oauth_access_tokens
+		WHERE oauth_access_tokens.user_id = sqlc.arg('user_id')::uuid
+		AND sqlc.narg('scope')::text = ANY(string_to_array(oauth_access_tokens.scope, ' '))
+		AND revoked_at IS NULL
+		AND expires_at > NOW()
+	)
+);
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, updated_at, subscription_id, event_id, event_type, payload, traceparent, status, next_attempt_at)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	'pending',
+	NOW()
+);
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Pushing next_attempt_at out leases the deliveries to this worker, so a
+-- crashed worker's deliveries are picked up again once the lease lapses.
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg('lease_until'),
+updated_at = NOW()
+WHERE id IN (
+	SELECT id
+	FROM webhook_deliveries
+	WHERE status IN ('pending', 'retrying')
+	AND next_attempt_at <= NOW()
+	ORDER BY next_attempt_at
+	LIMIT sqlc.arg('max_results')
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordWebhookDeliveryAttempt :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg('status'),
+attempts = attempts + 1,
+last_attempt_at = NOW(),
+next_attempt_at = sqlc.arg('next_attempt_at'),
+response_status = sqlc.narg('response_status'),
+last_error = sqlc.narg('last_error'),
+delivered_at = CASE WHEN sqlc.arg('status')::text = 'succeeded' THEN NOW() ELSE NULL END,
+updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: ListWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE subscription_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: RequeueWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending',
+attempts = 0,
+next_attempt_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+AND subscription_id = $2
+AND status = 'dead'
+RETURNING *;
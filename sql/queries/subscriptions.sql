@@ -0,0 +1,44 @@
+-- name: ActivateSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status, current_period_end)
+VALUES (
+	$1,
+	NOW(),
+	NOW(),
+	$2,
+	'active',
+	$3
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+status = 'active',
+current_period_end = EXCLUDED.current_period_end,
+updated_at = NOW()
+RETURNING *;
+
+-- name: SetSubscriptionStatus :one
+UPDATE subscriptions
+SET status = $2,
+updated_at = NOW()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: GetSubscription :one
+SELECT *
+FROM subscriptions
+WHERE user_id = $1;
+
+-- name: ExpireLapsedSubscriptions :many
+WITH expired AS (
+	UPDATE subscriptions
+	SET status = 'expired',
+	updated_at = NOW()
+	WHERE status <> 'expired'
+	AND current_period_end IS NOT NULL
+	AND current_period_end < NOW()
+	RETURNING subscriptions.user_id
+)
+UPDATE users
+SET is_chirpy_red = false,
+updated_at = NOW()
+WHERE id IN (SELECT user_id FROM expired)
+RETURNING id;
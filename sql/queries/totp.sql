@@ -0,0 +1,80 @@
+-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2,
+totp_enabled = false,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled = true,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (id, created_at, user_id, code_hash, used_at)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	NULL
+);
+
+-- name: UseRecoveryCode :one
+UPDATE recovery_codes SET used_at = NOW()
+WHERE user_id = $1
+AND code_hash = $2
+AND used_at IS NULL
+RETURNING *;
+
+-- name: CreateTOTPChallenge :exec
+INSERT INTO totp_challenges (id, created_at, user_id, expires_at, failed_attempts, used_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	0,
+	NULL
+);
+
+-- name: GetTOTPChallenge :one
+SELECT *
+FROM totp_challenges
+WHERE id = $1
+AND user_id = $2;
+
+-- name: RecordTOTPChallengeFailure :exec
+UPDATE totp_challenges
+SET failed_attempts = failed_attempts + 1
+WHERE id = $1;
+
+-- name: UseTOTPChallenge :execrows
+UPDATE totp_challenges
+SET used_at = NOW()
+WHERE id = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+AND failed_attempts < sqlc.arg('max_attempts')::int;
+
+-- name: CountRecentTOTPFailures :one
+SELECT COALESCE(SUM(failed_attempts), 0)::int
+FROM totp_challenges
+WHERE user_id = $1
+AND created_at > $2;
+
+-- name: DeleteStaleTOTPChallenges :exec
+DELETE FROM totp_challenges
+WHERE user_id = $1
+AND created_at < $2;
+
+-- name: SetTOTPLastStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1
+AND (totp_last_step IS NULL OR totp_last_step < $2);
@@ -9,16 +9,38 @@ VALUES (
 )
 RETURNING *;
 
+-- name: GetUserByID :one
+SELECT *
+FROM users
+WHERE id = $1;
+
 -- name: UpdateUser :one
 UPDATE users
 SET 
-	email = COALESCE($2, email), 
-	hashed_password = COALESCE($3, hashed_password),
+	email = COALESCE(sqlc.narg('email'), email), 
+	hashed_password = COALESCE(sqlc.narg('hashed_password'), hashed_password),
+	email_verified = email_verified AND email = COALESCE(sqlc.narg('email'), email),
 	updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: MarkEmailVerified :one
+UPDATE users
+SET email_verified = true,
+updated_at = NOW()
 WHERE id = $1
+AND email = $2
 RETURNING *;
 
--- name: UpgradeToChirpyRed :exec
+-- name: SetUserPassword :exec
 UPDATE users
-SET is_chirpy_red = true
-WHERE id = $1;
\ No newline at end of file
+SET hashed_password = $2,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserRoleByEmail :one
+UPDATE users
+SET role = $2,
+updated_at = NOW()
+WHERE email = $1
+RETURNING *;
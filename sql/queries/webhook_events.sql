@@ -0,0 +1,55 @@
+-- name: ClaimWebhookEvent :execrows
+INSERT INTO processed_webhook_events (id, created_at, event)
+VALUES (
+	$1,
+	NOW(),
+	$2
+)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: CreateInboundWebhook :one
+INSERT INTO inbound_webhooks (id, received_at, source, headers, payload, status)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	'received'
+)
+RETURNING *;
+
+-- name: FinishInboundWebhook :exec
+UPDATE inbound_webhooks
+SET event_id = $2,
+event_type = $3,
+status = $4,
+error = $5,
+payload = CASE WHEN $4 = 'rejected' THEN LEFT(payload, sqlc.arg('rejected_payload_bytes')::int) ELSE payload END,
+attempts = attempts + 1,
+processed_at = NOW()
+WHERE id = $1;
+
+-- name: MarkInboundWebhookAuthenticated :exec
+UPDATE inbound_webhooks
+SET status = 'authenticated'
+WHERE id = $1
+AND status = 'received';
+
+-- name: DeleteRejectedInboundWebhooks :execrows
+DELETE FROM inbound_webhooks
+WHERE status = 'rejected'
+AND received_at < $1;
+
+-- name: GetInboundWebhook :one
+SELECT *
+FROM inbound_webhooks
+WHERE id = $1;
+
+-- name: ListInboundWebhooks :many
+SELECT *
+FROM inbound_webhooks
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+AND (sqlc.narg('event_type')::text IS NULL OR event_type = sqlc.narg('event_type'))
+ORDER BY received_at DESC
+LIMIT sqlc.arg('max_results');
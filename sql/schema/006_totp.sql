@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN totp_secret TEXT NULL;
+
+ALTER TABLE users
+ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE recovery_codes (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP NULL,
+
+	FOREIGN KEY (user_id) REFERENCES users(id) on DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+DROP COLUMN totp_enabled;
+
+ALTER TABLE users
+DROP COLUMN totp_secret;
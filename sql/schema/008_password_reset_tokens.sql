@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE password_reset_tokens (
+	token_hash TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP NULL,
+
+	FOREIGN KEY (user_id) REFERENCES users(id) on DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE password_reset_tokens;
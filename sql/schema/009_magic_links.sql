@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE magic_links (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP NULL,
+
+	FOREIGN KEY (user_id) REFERENCES users(id) on DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE magic_links;
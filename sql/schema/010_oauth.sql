@@ -0,0 +1,45 @@
+-- +goose Up
+CREATE TABLE oauth_clients (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	name TEXT NOT NULL,
+	secret_hash TEXT NULL,
+	redirect_uris TEXT[] NOT NULL,
+	owner_id UUID NOT NULL,
+
+	FOREIGN KEY (owner_id) REFERENCES users(id) on DELETE CASCADE
+);
+
+CREATE TABLE oauth_authorization_codes (
+	code_hash TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	client_id TEXT NOT NULL,
+	user_id UUID NOT NULL,
+	redirect_uri TEXT NOT NULL,
+	scope TEXT NOT NULL,
+	code_challenge TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP NULL,
+
+	FOREIGN KEY (client_id) REFERENCES oauth_clients(id) on DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) on DELETE CASCADE
+);
+
+CREATE TABLE oauth_access_tokens (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	client_id TEXT NOT NULL,
+	user_id UUID NOT NULL,
+	scope TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP NULL,
+
+	FOREIGN KEY (client_id) REFERENCES oauth_clients(id) on DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) on DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE oauth_access_tokens;
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE user_identities (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	issuer TEXT NOT NULL,
+	subject TEXT NOT NULL,
+
+	UNIQUE (issuer, subject),
+	FOREIGN KEY (user_id) REFERENCES users(id) on DELETE CASCADE
+);
+
+CREATE TABLE oidc_login_states (
+	state TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	nonce TEXT NOT NULL,
+	code_verifier TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_login_states;
+DROP TABLE user_identities;
@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE audit_events (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	action TEXT NOT NULL,
+	outcome TEXT NOT NULL CHECK (outcome IN ('success', 'failure')),
+	actor_id UUID NULL,
+	target_id UUID NULL,
+	ip_address TEXT NOT NULL,
+	user_agent TEXT NOT NULL,
+	detail TEXT NOT NULL
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events (created_at);
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id);
+CREATE INDEX audit_events_target_id_idx ON audit_events (target_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_append_only
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TRIGGER audit_events_append_only ON audit_events;
+DROP FUNCTION audit_events_append_only();
+DROP TABLE audit_events;
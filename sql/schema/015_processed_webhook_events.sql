@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE processed_webhook_events (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	event TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE processed_webhook_events;
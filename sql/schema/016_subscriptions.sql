@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+	user_id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	plan TEXT NOT NULL,
+	status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'canceled', 'expired')),
+	current_period_end TIMESTAMP NULL,
+
+	FOREIGN KEY (user_id) REFERENCES users(id) on DELETE CASCADE
+);
+
+-- Existing Chirpy Red members keep their membership with no end date.
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status, current_period_end)
+SELECT id, NOW(), NOW(), 'chirpy_red', 'active', NULL
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN publish_at TIMESTAMP NULL,
+ADD COLUMN media_path TEXT NULL;
+
+UPDATE chirps
+SET publish_at = created_at;
+
+ALTER TABLE chirps
+ALTER COLUMN publish_at SET NOT NULL;
+
+CREATE INDEX chirps_publish_at_idx ON chirps (publish_at);
+
+-- +goose Down
+DROP INDEX chirps_publish_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN media_path,
+DROP COLUMN publish_at;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE inbound_webhooks (
+	id UUID PRIMARY KEY,
+	received_at TIMESTAMP NOT NULL,
+	source TEXT NOT NULL,
+	headers JSONB NOT NULL,
+	payload TEXT NOT NULL,
+	event_id TEXT NULL,
+	event_type TEXT NULL,
+	status TEXT NOT NULL CHECK (status IN ('received', 'processed', 'ignored', 'duplicate', 'failed', 'rejected')),
+	error TEXT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	processed_at TIMESTAMP NULL
+);
+
+CREATE INDEX inbound_webhooks_received_at_idx ON inbound_webhooks (received_at);
+
+-- +goose Down
+DROP TABLE inbound_webhooks;
@@ -0,0 +1,41 @@
+-- +goose Up
+CREATE TABLE webhook_subscriptions (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	owner_id UUID NULL,
+	client_id TEXT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL,
+
+	CHECK ((owner_id IS NULL) <> (client_id IS NULL)),
+	FOREIGN KEY (owner_id) REFERENCES users(id) on DELETE CASCADE,
+	FOREIGN KEY (client_id) REFERENCES oauth_clients(id) on DELETE CASCADE
+);
+
+CREATE TABLE webhook_deliveries (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	subscription_id UUID NOT NULL,
+	event_id UUID NOT NULL,
+	event_type TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL CHECK (status IN ('pending', 'retrying', 'succeeded', 'dead')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL,
+	last_attempt_at TIMESTAMP NULL,
+	response_status INTEGER NULL,
+	last_error TEXT NULL,
+	delivered_at TIMESTAMP NULL,
+
+	FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) on DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+WHERE status IN ('pending', 'retrying');
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE totp_challenges (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	failed_attempts INTEGER NOT NULL DEFAULT 0,
+	used_at TIMESTAMP NULL,
+
+	FOREIGN KEY (user_id) REFERENCES users(id) on DELETE CASCADE
+);
+
+CREATE INDEX totp_challenges_user_id_created_at_idx ON totp_challenges (user_id, created_at);
+
+ALTER TABLE users
+ADD COLUMN totp_last_step BIGINT NULL;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN totp_last_step;
+
+DROP TABLE totp_challenges;
@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE inbound_webhooks
+DROP CONSTRAINT inbound_webhooks_status_check;
+
+ALTER TABLE inbound_webhooks
+ADD CONSTRAINT inbound_webhooks_status_check
+CHECK (status IN ('received', 'authenticated', 'processed', 'ignored', 'duplicate', 'failed', 'rejected'));
+
+CREATE INDEX inbound_webhooks_status_received_at_idx ON inbound_webhooks (status, received_at);
+
+-- +goose Down
+DROP INDEX inbound_webhooks_status_received_at_idx;
+
+UPDATE inbound_webhooks
+SET status = 'received'
+WHERE status = 'authenticated';
+
+ALTER TABLE inbound_webhooks
+DROP CONSTRAINT inbound_webhooks_status_check;
+
+ALTER TABLE inbound_webhooks
+ADD CONSTRAINT inbound_webhooks_status_check
+CHECK (status IN ('received', 'processed', 'ignored', 'duplicate', 'failed', 'rejected'));
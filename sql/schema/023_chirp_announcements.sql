@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN announced_at TIMESTAMP NULL;
+
+-- Chirps from before this column were announced when they were created.
+UPDATE chirps
+SET announced_at = created_at;
+
+CREATE INDEX chirps_unannounced_publish_at_idx ON chirps (publish_at)
+WHERE announced_at IS NULL;
+
+-- +goose Down
+DROP INDEX chirps_unannounced_publish_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN announced_at;
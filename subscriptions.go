@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/database"
+	"github.com/jzetterman/chirpy/internal/metrics"
+	"github.com/jzetterman/chirpy/internal/tracing"
+	"github.com/lib/pq"
+)
+
+const (
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+	subscriptionExpired  = "expired"
+)
+
+var (
+	errSubscriptionUser = errors.New("subscription user doesn't exist")
+	errNoSubscription   = errors.New("user has no subscription")
+)
+
+type Subscription struct {
+	Plan             string     `json:"plan"`
+	Status           string     `json:"status"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func newSubscription(sub database.Subscription) Subscription {
+	subscription := Subscription{
+		Plan:      sub.Plan,
+		Status:    sub.Status,
+		UpdatedAt: sub.UpdatedAt,
+	}
+	if sub.CurrentPeriodEnd.Valid {
+		subscription.CurrentPeriodEnd = &sub.CurrentPeriodEnd.Time
+	}
+	return subscription
+}
+
+// inTx runs fn with queries in a single transaction, committing if it returns
+// nil and rolling back otherwise.
+func (cfg *apiConfig) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(database.New(instrumentDB(cfg.metrics, tx)))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// instrumentDB wraps a database handle or transaction in the query metrics
+// and tracing every database.Queries gets.
+func instrumentDB(m *metrics.Metrics, db metrics.DBTX) metrics.DBTX {
+	return tracing.InstrumentDB(m.InstrumentDB(db))
+}
+
+// activateSubscription starts or renews a user's plan and turns on Chirpy
+// Red. A zero periodEnd never lapses, which is how manual grants work.
+func (cfg *apiConfig) activateSubscription(ctx context.Context, userID uuid.UUID, plan string, periodEnd time.Time) error {
+	var sub database.Subscription
+	err := cfg.inTx(ctx, func(q *database.Queries) error {
+		var err error
+		sub, err = activateSubscriptionWith(ctx, q, userID, plan, periodEnd)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.emitUserUpgraded(ctx, userID, sub)
+	return nil
+}
+
+// activateSubscriptionWith is activateSubscription for a caller that has its
+// own transaction, and so has to emit the upgrade event once it commits.
+func activateSubscriptionWith(ctx context.Context, q *database.Queries, userID uuid.UUID, plan string, periodEnd time.Time) (database.Subscription, error) {
+	sub, err := q.ActivateSubscription(ctx, database.ActivateSubscriptionParams{
+		UserID:           userID,
+		Plan:             plan,
+		CurrentPeriodEnd: sql.NullTime{Time: periodEnd.UTC(), Valid: !periodEnd.IsZero()},
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return database.Subscription{}, errSubscriptionUser
+		}
+		return database.Subscription{}, err
+	}
+
+	_, err = q.SetChirpyRed(ctx, database.SetChirpyRedParams{
+		ID:          userID,
+		IsChirpyRed: true,
+	})
+	if err != nil {
+		return database.Subscription{}, err
+	}
+	return sub, nil
+}
+
+func (cfg *apiConfig) emitUserUpgraded(ctx context.Context, userID uuid.UUID, sub database.Subscription) {
+	cfg.emitEvent(ctx, eventUserUpgraded, userID, struct {
+		UserID uuid.UUID `json:"user_id"`
+		Subscription
+	}{
+		UserID:       userID,
+		Subscription: newSubscription(sub),
+	})
+}
+
+// changeSubscriptionStatus moves a subscription to status. Expiring takes
+// Chirpy Red away straight away; past due and canceled subscriptions keep it
+// until the paid period ends, unless there is no period end to wait for, in
+// which case they expire now.
+func (cfg *apiConfig) changeSubscriptionStatus(ctx context.Context, userID uuid.UUID, status string) error {
+	return cfg.inTx(ctx, func(q *database.Queries) error {
+		return changeSubscriptionStatusWith(ctx, q, userID, status)
+	})
+}
+
+// changeSubscriptionStatusWith is changeSubscriptionStatus for a caller that
+// has its own transaction.
+func changeSubscriptionStatusWith(ctx context.Context, q *database.Queries, userID uuid.UUID, status string) error {
+	sub, err := q.GetSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNoSubscription
+	}
+	if err != nil {
+		return err
+	}
+
+	if (status == subscriptionCanceled || status == subscriptionPastDue) && !sub.CurrentPeriodEnd.Valid {
+		status = subscriptionExpired
+	}
+
+	_, err = q.SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{
+		UserID: userID,
+		Status: status,
+	})
+	if err != nil {
+		return err
+	}
+
+	if status != subscriptionExpired {
+		return nil
+	}
+
+	_, err = q.SetChirpyRed(ctx, database.SetChirpyRedParams{
+		ID:          userID,
+		IsChirpyRed: false,
+	})
+	return err
+}
+
+// expireLapsedSubscriptions ends every subscription whose period is over
+// without a renewal.
+func (cfg *apiConfig) expireLapsedSubscriptions(ctx context.Context) error {
+	userIDs, err := cfg.database.ExpireLapsedSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		cfg.auditSystem(ctx, auditEvent{
+			Action:   auditSubscriptionExpire,
+			Outcome:  auditSuccess,
+			TargetID: userID,
+			Detail:   "period ended without renewal",
+		})
+	}
+
+	return nil
+}
+
+// runSubscriptionExpiry expires lapsed subscriptions every interval until ctx
+// is done.
+func (cfg *apiConfig) runSubscriptionExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	cfg.workers.started(workerSubscriptionExpiry, interval)
+
+	for {
+		err := cfg.expireLapsedSubscriptions(ctx)
+		cfg.workers.ran(workerSubscriptionExpiry, err)
+		if err != nil {
+			cfg.logger.Error("Error expiring subscriptions", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
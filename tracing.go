@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/jzetterman/chirpy/internal/metrics"
+	"github.com/jzetterman/chirpy/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// middlewareTrace runs each request in a server span, continuing the
+// caller's trace when the request carries a W3C traceparent header. The
+// request's log lines are tagged with the trace ID.
+func (cfg *apiConfig) middlewareTrace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			addRequestLogAttrs(ctx, "trace_id", sc.TraceID().String())
+		}
+
+		r = r.WithContext(ctx)
+		rec := metrics.NewStatusRecorder(w)
+
+		next.ServeHTTP(rec, r)
+		noteRequestRoute(ctx, r.Pattern)
+
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+		}
+		span.SetAttributes(tracing.HTTPAttributes(r, rec.Status())...)
+		if rec.Status() > 499 {
+			span.SetStatus(codes.Error, http.StatusText(rec.Status()))
+		}
+	})
+}
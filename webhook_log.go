@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jzetterman/chirpy/internal/database"
+)
+
+const webhookSourcePolka = "polka"
+
+const (
+	webhookReceived = "received"
+	// webhookAuthenticated is recorded as soon as a webhook passes
+	// authentication, so one left in this status was lost mid-processing
+	// and can be replayed.
+	webhookAuthenticated = "authenticated"
+	webhookProcessed     = "processed"
+	webhookIgnored       = "ignored"
+	webhookDuplicate     = "duplicate"
+	webhookFailed        = "failed"
+	webhookRejected      = "rejected"
+)
+
+const (
+	// rejectedWebhookPayloadBytes is how much of a rejected webhook's
+	// payload is kept. Anyone can send one, so they're kept short, and
+	// deleted after rejectedWebhookRetention.
+	rejectedWebhookPayloadBytes = 4 << 10
+	rejectedWebhookRetention    = 7 * 24 * time.Hour
+)
+
+// redactedWebhookHeaders are never written to the webhook log.
+var redactedWebhookHeaders = []string{"Authorization", "Cookie"}
+
+// logInboundWebhook stores a webhook exactly as it arrived, before anything
+// is checked, so nothing is lost if processing fails. It returns uuid.Nil if
+// the webhook couldn't be stored; processing carries on regardless.
+func (cfg *apiConfig) logInboundWebhook(r *http.Request, source string, body []byte) uuid.UUID {
+	headers := r.Header.Clone()
+	for _, name := range redactedWebhookHeaders {
+		if headers.Get(name) != "" {
+			headers.Set(name, "[redacted]")
+		}
+	}
+
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error encoding webhook headers", "source", source, "error", err)
+		return uuid.Nil
+	}
+
+	webhook, err := cfg.database.CreateInboundWebhook(r.Context(), database.CreateInboundWebhookParams{
+		Source:  source,
+		Headers: encodedHeaders,
+		Payload: string(body),
+	})
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error logging webhook", "source", source, "error", err)
+		return uuid.Nil
+	}
+
+	return webhook.ID
+}
+
+// finishInboundWebhook records how processing a logged webhook went.
+func (cfg *apiConfig) finishInboundWebhook(ctx context.Context, source string, id uuid.UUID, eventID, eventType, status string, processErr error) {
+	cfg.metrics.WebhooksProcessed.WithLabelValues(source, status).Inc()
+
+	if id == uuid.Nil {
+		return
+	}
+
+	errorMessage := sql.NullString{}
+	if processErr != nil {
+		errorMessage = sql.NullString{String: processErr.Error(), Valid: true}
+	}
+
+	err := cfg.database.FinishInboundWebhook(ctx, database.FinishInboundWebhookParams{
+		ID:        id,
+		EventID:   sql.NullString{String: eventID, Valid: eventID != ""},
+		EventType: sql.NullString{String: eventType, Valid: eventType != ""},
+		Status:    status,
+		Error:     errorMessage,
+
+		RejectedPayloadBytes: rejectedWebhookPayloadBytes,
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("Error updating webhook log", "webhook_id", id, "error", err)
+	}
+}
+
+// markInboundWebhookAuthenticated records that a logged webhook passed
+// authentication and is about to be processed.
+func (cfg *apiConfig) markInboundWebhookAuthenticated(ctx context.Context, id uuid.UUID) {
+	if id == uuid.Nil {
+		return
+	}
+
+	err := cfg.database.MarkInboundWebhookAuthenticated(ctx, id)
+	if err != nil {
+		loggerFromContext(ctx).Error("Error updating webhook log", "webhook_id", id, "error", err)
+	}
+}
+
+// runWebhookLogRetention deletes old rejected webhooks every interval until
+// ctx is done. Authenticated webhooks are kept for replay and auditing.
+func (cfg *apiConfig) runWebhookLogRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	cfg.workers.started(workerWebhookLogRetention, interval)
+
+	for {
+		deleted, err := cfg.database.DeleteRejectedInboundWebhooks(ctx, time.Now().UTC().Add(-rejectedWebhookRetention))
+		cfg.workers.ran(workerWebhookLogRetention, err)
+		if err != nil {
+			cfg.logger.Error("Error deleting rejected webhooks", "error", err)
+		} else if deleted > 0 {
+			cfg.logger.Info("Deleted rejected webhooks", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type InboundWebhook struct {
+	ID          uuid.UUID       `json:"id"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Source      string          `json:"source"`
+	EventID     string          `json:"event_id,omitempty"`
+	EventType   string          `json:"event_type,omitempty"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int32           `json:"attempts"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+	Headers     json.RawMessage `json:"headers,omitempty"`
+	Payload     string          `json:"payload,omitempty"`
+}
+
+func newInboundWebhook(webhook database.InboundWebhook) InboundWebhook {
+	result := InboundWebhook{
+		ID:         webhook.ID,
+		ReceivedAt: webhook.ReceivedAt,
+		Source:     webhook.Source,
+		EventID:    webhook.EventID.String,
+		EventType:  webhook.EventType.String,
+		Status:     webhook.Status,
+		Error:      webhook.Error.String,
+		Attempts:   webhook.Attempts,
+	}
+	if webhook.ProcessedAt.Valid {
+		result.ProcessedAt = &webhook.ProcessedAt.Time
+	}
+	return result
+}
+
+func (cfg *apiConfig) adminWebhooksListHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := database.ListInboundWebhooksParams{
+		MaxResults: 100,
+	}
+
+	if status := query.Get("status"); status != "" {
+		params.Status = sql.NullString{String: status, Valid: true}
+	}
+
+	if eventType := query.Get("event_type"); eventType != "" {
+		params.EventType = sql.NullString{String: eventType, Valid: true}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			respondWithError(w, r, http.StatusBadRequest, "limit must be between 1 and 1000", err)
+			return
+		}
+		params.MaxResults = int32(limit)
+	}
+
+	dbWebhooks, err := cfg.database.ListInboundWebhooks(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list webhooks", err)
+		return
+	}
+
+	webhooks := []InboundWebhook{}
+	for _, dbWebhook := range dbWebhooks {
+		webhooks = append(webhooks, newInboundWebhook(dbWebhook))
+	}
+
+	respondWithJSON(w, http.StatusOK, webhooks)
+}
+
+// adminWebhookFromPath loads the webhook named by the {webhookID} path value,
+// writing an error response and returning false if it can't.
+func (cfg *apiConfig) adminWebhookFromPath(w http.ResponseWriter, r *http.Request) (database.InboundWebhook, bool) {
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid webhook ID", err)
+		return database.InboundWebhook{}, false
+	}
+
+	webhook, err := cfg.database.GetInboundWebhook(r.Context(), webhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find webhook", err)
+		return database.InboundWebhook{}, false
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get webhook", err)
+		return database.InboundWebhook{}, false
+	}
+
+	return webhook, true
+}
+
+func (cfg *apiConfig) adminWebhookGetHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := cfg.adminWebhookFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	result := newInboundWebhook(webhook)
+	result.Headers = webhook.Headers
+	result.Payload = webhook.Payload
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// adminWebhookReplayHandler runs a stored webhook through processing again.
+// Unauthenticated webhooks are never replayed, and an event that
+// was already applied is recorded as a duplicate rather than applied twice.
+// Webhooks still marked authenticated were lost mid-processing and are the
+// main reason to replay.
+func (cfg *apiConfig) adminWebhookReplayHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := cfg.adminWebhookFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// Webhooks still marked received never got past authentication, either
+	// because it failed before it was recorded or they were never checked.
+	if webhook.Status == webhookReceived || webhook.Status == webhookRejected {
+		respondWithError(w, r, http.StatusConflict, "Only authenticated webhooks can be replayed", nil)
+		return
+	}
+
+	if webhook.Source != webhookSourcePolka {
+		respondWithError(w, r, http.StatusConflict, "Webhooks from "+webhook.Source+" can't be replayed", nil)
+		return
+	}
+
+	event, status, whErr := cfg.processPolkaWebhook(r, []byte(webhook.Payload))
+	if whErr != nil {
+		cfg.finishInboundWebhook(r.Context(), webhook.Source, webhook.ID, event.ID, event.Event, status, whErr)
+		cfg.auditAdmin(r, auditAdminWebhookReplay, uuid.Nil, "webhook "+webhook.ID.String(), whErr)
+	} else {
+		cfg.finishInboundWebhook(r.Context(), webhook.Source, webhook.ID, event.ID, event.Event, status, nil)
+		cfg.auditAdmin(r, auditAdminWebhookReplay, uuid.Nil, "webhook "+webhook.ID.String()+": "+status, nil)
+	}
+
+	updated, err := cfg.database.GetInboundWebhook(r.Context(), webhook.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get webhook", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newInboundWebhook(updated))
+}
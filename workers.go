@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	workerChirpAnnouncer      = "chirp_announcer"
+	workerSubscriptionExpiry  = "subscription_expiry"
+	workerWebhookDispatcher   = "webhook_dispatcher"
+	workerWebhookLogRetention = "webhook_log_retention"
+)
+
+// backgroundTaskTimeout bounds work a request hands off with runInBackground.
+const backgroundTaskTimeout = time.Minute
+
+// workerStaleAfter is how many intervals a worker may go without a
+// successful run before it's reported unhealthy.
+const workerStaleAfter = 3
+
+// workerMonitor tracks how each background worker's runs are going, so
+// readiness can tell a stuck or failing worker from a healthy one.
+type workerMonitor struct {
+	mu      sync.Mutex
+	workers map[string]*workerState
+}
+
+type workerState struct {
+	interval    time.Duration
+	startedAt   time.Time
+	lastRunAt   time.Time
+	lastSuccess time.Time
+	lastErr     error
+}
+
+type WorkerStatus struct {
+	Status        string     `json:"status"`
+	LastRunAt     *time.Time `json:"last_run_at"`
+	LastSuccessAt *time.Time `json:"last_success_at"`
+	// LastError is logged rather than reported, since readyz is public.
+	LastError string `json:"-"`
+}
+
+func newWorkerMonitor() *workerMonitor {
+	return &workerMonitor{workers: map[string]*workerState{}}
+}
+
+// started registers a worker that runs every interval.
+func (m *workerMonitor) started(name string, interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.workers[name] = &workerState{
+		interval:  interval,
+		startedAt: time.Now(),
+	}
+}
+
+// ran records the outcome of one of a worker's runs.
+func (m *workerMonitor) ran(name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.workers[name]
+	if !ok {
+		return
+	}
+
+	state.lastRunAt = time.Now()
+	state.lastErr = err
+	if err == nil {
+		state.lastSuccess = state.lastRunAt
+	}
+}
+
+// status reports on every registered worker. A worker is healthy if it has
+// succeeded recently, or has only just started and not failed yet.
+func (m *workerMonitor) status(now time.Time) (bool, map[string]WorkerStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	healthy := true
+	statuses := map[string]WorkerStatus{}
+	for name, state := range m.workers {
+		// Copies, since the state keeps changing once the lock is released.
+		lastRunAt, lastSuccess := state.lastRunAt, state.lastSuccess
+
+		status := WorkerStatus{Status: checkOK}
+		if !lastRunAt.IsZero() {
+			status.LastRunAt = &lastRunAt
+		}
+		if !lastSuccess.IsZero() {
+			status.LastSuccessAt = &lastSuccess
+		}
+		if state.lastErr != nil {
+			status.LastError = state.lastErr.Error()
+		}
+
+		since := state.lastSuccess
+		if since.IsZero() {
+			since = state.startedAt
+		}
+		if now.Sub(since) > workerStaleAfter*state.interval || (state.lastSuccess.IsZero() && state.lastErr != nil) {
+			status.Status = checkFailed
+			healthy = false
+		}
+
+		statuses[name] = status
+	}
+
+	return healthy, statuses
+}
+
+// runInBackground runs fn once the request that started it no longer waits
+// on it, for work like sending email whose duration shouldn't show in the
+// response. fn keeps the request's logger and trace but not its
+// cancellation, and shutdown waits for it alongside the workers.
+func (cfg *apiConfig) runInBackground(ctx context.Context, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundTaskTimeout)
+	cfg.background.Add(1)
+	go func() {
+		defer cfg.background.Done()
+		defer cancel()
+		fn(ctx)
+	}()
+}